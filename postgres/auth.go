@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// DevUserIDHeader carries the caller's user ID when the dev-mode bypass is enabled
+const DevUserIDHeader = "X-Dev-User-ID"
+
+// AccessTokenParam is the query parameter that carries the bearer token on streaming
+// endpoints, for clients such as browser WebSockets that cannot set headers
+const AccessTokenParam = "access_token"
+
+// AuthConfig configures bearer token authentication.
+// At least one of HMACSecret and RSAPublicKey must be set unless DevBypass is enabled.
+type AuthConfig struct {
+	HMACSecret   []byte         // enables HS256 tokens
+	RSAPublicKey *rsa.PublicKey // enables RS256 tokens
+	Issuer       string         // required "iss" claim, if set
+	Audience     string         // required "aud" claim, if set
+	// DevBypass lets requests without a token authenticate with the DevUserIDHeader.
+	// For local testing only: never enable it in production.
+	DevBypass bool
+}
+
+// Authenticator validates JWT bearer tokens and stores the caller's user ID in the request context
+type Authenticator struct {
+	cfg    AuthConfig
+	parser *jwt.Parser
+}
+
+// NewAuthenticator creates an authenticator for the given configuration
+func NewAuthenticator(cfg AuthConfig) (*Authenticator, error) {
+	var methods []string
+	if len(cfg.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RSAPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 && !cfg.DevBypass {
+		return nil, errors.New("auth: no token verification key configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &Authenticator{
+		cfg:    cfg,
+		parser: jwt.NewParser(opts...),
+	}, nil
+}
+
+// Middleware rejects requests without valid credentials with 401
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// QueryTokenMiddleware is Middleware that also accepts the token in the AccessTokenParam
+// query parameter. Only use it for streaming endpoints: URLs tend to end up in logs.
+func (a *Authenticator) QueryTokenMiddleware(next http.Handler) http.Handler {
+	authenticated := a.Middleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get(AccessTokenParam); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns the user ID of the request's bearer token (or dev header)
+func (a *Authenticator) authenticate(r *http.Request) (uuid.UUID, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if a.cfg.DevBypass {
+			if id, err := uuid.Parse(r.Header.Get(DevUserIDHeader)); err == nil {
+				return id, nil
+			}
+		}
+		return uuid.Nil, errors.New("missing bearer token")
+	}
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return uuid.Nil, errors.New("authorization header must use the Bearer scheme")
+	}
+	return a.ParseToken(token)
+}
+
+// ParseToken validates a token and returns the user ID from its "sub" claim
+func (a *Authenticator) ParseToken(token string) (uuid.UUID, error) {
+	var claims jwt.RegisteredClaims
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return uuid.Nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errors.New("invalid token: subject is not a user ID")
+	}
+	return userID, nil
+}
+
+// key returns the verification key for the token's signing method
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.cfg.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		return a.cfg.RSAPublicKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+type contextKey int
+
+const userIDContextKey contextKey = iota
+
+// WithUserID returns a context carrying the authenticated user ID
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// UserIDFromContext returns the authenticated user ID set by the auth middleware
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDContextKey).(uuid.UUID)
+	return userID, ok
+}
+
+// requireUserID returns the authenticated user ID, responding 401 if there is none
+func requireUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := UserIDFromContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "authentication required")
+	}
+	return userID, ok
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrObjectNotFound is returned by a BlobStore when no object exists under a key
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// BlobStore stores photo bytes under the photo's key
+type BlobStore interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Stat returns the object metadata without reading its content
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL of the object
+	URL(key string) string
+}
+
+// LocalBlobStore stores objects as files below a root directory
+type LocalBlobStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalBlobStore creates a blob store rooted at dir.
+// baseURL is the URL prefix under which the directory is served.
+func NewLocalBlobStore(dir, baseURL string) (*LocalBlobStore, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve blob directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &LocalBlobStore{
+		root:    root,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// Root returns the directory objects are stored in
+func (s *LocalBlobStore) Root() string {
+	return s.root
+}
+
+// path maps a key to a file below the root, rejecting keys that escape it
+func (s *LocalBlobStore) path(key string) (string, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	if key == "" || !strings.HasPrefix(p, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return p, nil
+}
+
+// Put writes the object to a temporary file and renames it into place,
+// so readers never observe a partially written object
+func (s *LocalBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create object file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("object size mismatch: expected %d bytes, got %d", size, written)
+	}
+
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store object: %w", err)
+	}
+	return nil
+}
+
+// Get opens the object file
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return f, &ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime()}, nil
+}
+
+// Stat returns the size and modification time of the object file
+func (s *LocalBlobStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return &ObjectInfo{Key: key, Size: fi.Size(), LastModified: fi.ModTime()}, nil
+}
+
+// Delete removes the object file
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// URL returns the object URL below the configured base URL
+func (s *LocalBlobStore) URL(key string) string {
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath()
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3-compatible blob store (AWS S3, MinIO, R2, ...)
+type S3Config struct {
+	Endpoint        string // host[:port], e.g. "s3.amazonaws.com" or "localhost:9000"
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseSSL          bool
+	// PublicBaseURL overrides the URL prefix used for object URLs (e.g. a CDN).
+	// Defaults to the path-style bucket URL on the endpoint.
+	PublicBaseURL string
+}
+
+// S3BlobStore stores objects in an S3-compatible bucket
+type S3BlobStore struct {
+	client  *minio.Client
+	bucket  string
+	baseURL string
+}
+
+// NewS3BlobStore creates a blob store for the configured bucket.
+// Path-style addressing is used so local MinIO-style servers work without DNS setup.
+func NewS3BlobStore(cfg S3Config) (*S3BlobStore, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:       cfg.UseSSL,
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	baseURL := cfg.PublicBaseURL
+	if baseURL == "" {
+		scheme := "http"
+		if cfg.UseSSL {
+			scheme = "https"
+		}
+		baseURL = fmt.Sprintf("%s://%s/%s", scheme, cfg.Endpoint, cfg.Bucket)
+	}
+
+	return &S3BlobStore{
+		client:  client,
+		bucket:  cfg.Bucket,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// EnsureBucket creates the bucket if it does not exist yet
+func (s *S3BlobStore) EnsureBucket(ctx context.Context, region string) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+		return fmt.Errorf("failed to create bucket: %w", err)
+	}
+	return nil
+}
+
+// Put uploads the object
+func (s *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	return nil
+}
+
+// Get downloads the object
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	return obj, info, nil
+}
+
+// Stat returns the object metadata
+func (s *S3BlobStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// Delete removes the object
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+	return nil
+}
+
+// URL returns the object URL below the bucket (or public) base URL
+func (s *S3BlobStore) URL(key string) string {
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// SignURL returns a presigned GET URL for the object, served by S3 itself.
+// Presigned URLs always point at the endpoint, ignoring PublicBaseURL.
+func (s *S3BlobStore) SignURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, ttl, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign object URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// SignUploadURL returns a presigned PUT URL for the object
+func (s *S3BlobStore) SignUploadURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, key, ttl)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign upload URL: %w", err)
+	}
+	return u.String(), nil
+}
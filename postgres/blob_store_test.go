@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalBlobStorePath(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"photos/user/id.jpg", true},
+		{"photos/../photos/id.jpg", true},
+		{"id.jpg", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../outside.jpg", false},
+		{"photos/../../outside.jpg", false},
+		{"/photos/../../outside.jpg", false},
+	}
+	for _, tt := range tests {
+		p, err := store.path(tt.key)
+		if tt.valid {
+			if err != nil {
+				t.Errorf("path(%q): unexpected error %v", tt.key, err)
+			} else if !strings.HasPrefix(p, store.Root()+string(filepath.Separator)) {
+				t.Errorf("path(%q) = %q, outside of %q", tt.key, p, store.Root())
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("path(%q) = %q, want an error", tt.key, p)
+		}
+	}
+}
+
+func TestLocalBlobStorePut(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalBlobStore(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		data    string
+		size    int64
+		wantErr bool
+	}{
+		{"exact size", "photos/a.jpg", "hello", 5, false},
+		{"unknown size", "photos/b.jpg", "hello", -1, false},
+		{"too short", "photos/c.jpg", "hell", 5, true},
+		{"too long", "photos/d.jpg", "hello!", 5, true},
+		{"invalid key", "../e.jpg", "hello", 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := store.Put(ctx, tt.key, strings.NewReader(tt.data), tt.size, "image/jpeg")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if _, err := store.Stat(ctx, tt.key); err == nil {
+					t.Fatal("failed put left an object behind")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := store.Stat(ctx, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size != int64(len(tt.data)) {
+				t.Fatalf("stored %d bytes, want %d", info.Size, len(tt.data))
+			}
+		})
+	}
+
+	// Failed puts must not leave temporary files around
+	entries, err := os.ReadDir(filepath.Join(store.Root(), "photos"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".upload-") {
+			t.Errorf("temporary file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestLocalBlobStoreNotFound(t *testing.T) {
+	testBlobStoreNotFound(t, newTestLocalBlobStore(t))
+}
+
+func TestLocalBlobStoreRoundTrip(t *testing.T) {
+	testBlobStoreRoundTrip(t, newTestLocalBlobStore(t))
+}
+
+// TestS3BlobStore runs against the S3-compatible server at S3_TEST_ENDPOINT,
+// e.g. a local MinIO (see QUICKSTART.md)
+func TestS3BlobStore(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+	store, err := NewS3BlobStore(S3Config{
+		Endpoint:        endpoint,
+		Region:          "us-east-1",
+		Bucket:          envOr("S3_TEST_BUCKET", "photos-test"),
+		AccessKeyID:     envOr("S3_TEST_ACCESS_KEY_ID", "minioadmin"),
+		SecretAccessKey: envOr("S3_TEST_SECRET_ACCESS_KEY", "minioadmin"),
+		UseSSL:          os.Getenv("S3_TEST_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := store.EnsureBucket(ctx, "us-east-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("NotFound", func(t *testing.T) { testBlobStoreNotFound(t, store) })
+	t.Run("RoundTrip", func(t *testing.T) { testBlobStoreRoundTrip(t, store) })
+}
+
+func newTestLocalBlobStore(t *testing.T) *LocalBlobStore {
+	t.Helper()
+	store, err := NewLocalBlobStore(t.TempDir(), "http://localhost/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// testKey returns a key no other test run uses
+func testKey(t *testing.T) string {
+	return fmt.Sprintf("test/%s/%d.jpg", t.Name(), time.Now().UnixNano())
+}
+
+// testBlobStoreNotFound checks that missing objects are ErrObjectNotFound
+// and that deleting them succeeds
+func testBlobStoreNotFound(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := testKey(t)
+
+	if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get: got %v, want ErrObjectNotFound", err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat: got %v, want ErrObjectNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+}
+
+// testBlobStoreRoundTrip stores, reads and deletes an object
+func testBlobStoreRoundTrip(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	key := testKey(t)
+	data := "not really a jpeg"
+
+	if err := store.Put(ctx, key, strings.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Delete(ctx, key)
+
+	r, info, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data || info.Size != int64(len(data)) {
+		t.Fatalf("got %q (%d bytes), want %q", got, info.Size, data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("Stat after Delete: got %v, want ErrObjectNotFound", err)
+	}
+}
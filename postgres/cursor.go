@@ -0,0 +1,76 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultPageSize is used when a list request does not specify a limit
+	DefaultPageSize = 20
+	// MaxPageSize caps the number of items a single page can return
+	MaxPageSize = 100
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor error = &ValidationError{Field: "cursor", Message: "invalid cursor"}
+
+// pageCursor is the position after the last item of a page.
+// Lists are ordered by (created_at, id) descending, so the pair is unique and stable.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// encodeCursor returns an opaque cursor pointing after the given item
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	b, _ := json.Marshal(pageCursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(cursor string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// keyset holds the query arguments that position a keyset-paginated list
+type keyset struct {
+	HasCursor bool
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// parseKeyset decodes an optional cursor into query arguments.
+// An empty cursor starts at the first page.
+func parseKeyset(cursor string) (keyset, error) {
+	if cursor == "" {
+		return keyset{}, nil
+	}
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return keyset{}, err
+	}
+	return keyset{HasCursor: true, CreatedAt: c.CreatedAt, ID: c.ID}, nil
+}
+
+// clampPageSize keeps a requested page size within [1, MaxPageSize]
+func clampPageSize(limit int32) int32 {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
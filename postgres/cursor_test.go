@@ -0,0 +1,72 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+	}{
+		{"microseconds", time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC)},
+		{"whole seconds", time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)},
+		{"other zone", time.Date(2024, 3, 1, 12, 30, 45, 500, time.FixedZone("", -7*3600))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.New()
+			ks, err := parseKeyset(encodeCursor(tt.createdAt, id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ks.HasCursor || !ks.CreatedAt.Equal(tt.createdAt) || ks.ID != id {
+				t.Fatalf("got %+v, want %v and %v", ks, tt.createdAt, id)
+			}
+		})
+	}
+}
+
+func TestParseKeysetWithoutCursor(t *testing.T) {
+	ks, err := parseKeyset("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ks.HasCursor {
+		t.Fatalf("got %+v, want the first page", ks)
+	}
+}
+
+func TestParseKeysetInvalidCursor(t *testing.T) {
+	valid := encodeCursor(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), uuid.New())
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"truncated", valid[:len(valid)/2]},
+		{"altered", "x" + valid[1:]},
+		{"trailing data", encode(`{"t":"2024-03-01T00:00:00Z","id":"` + uuid.NewString() + `"}x`)},
+		{"not json", encode("2024-03-01")},
+		{"missing id", encode(`{"t":"2024-03-01T00:00:00Z"}`)},
+		{"nil id", encode(`{"t":"2024-03-01T00:00:00Z","id":"` + uuid.Nil.String() + `"}`)},
+		{"invalid id", encode(`{"t":"2024-03-01T00:00:00Z","id":"1234"}`)},
+		{"missing time", encode(`{"id":"` + uuid.NewString() + `"}`)},
+		{"invalid time", encode(`{"t":"yesterday","id":"` + uuid.NewString() + `"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := parseKeyset(tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("got %+v, %v, want ErrInvalidCursor", ks, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+type DeviceHandler struct {
+	deviceService *service.DeviceService
+}
+
+func NewDeviceHandler(deviceService *service.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		deviceService: deviceService,
+	}
+}
+
+// RegisterDevice godoc
+// @Summary Register a device for push notifications
+// @Description Store the push token of one of the authenticated user's devices. Registering a known token again updates it.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RegisterDeviceRequest true "Device token"
+// @Success 201 {object} service.DeviceResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/devices [post]
+func (h *DeviceHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	device, err := h.deviceService.RegisterDevice(r.Context(), userID, req.Platform, req.Token)
+	if err != nil {
+		respondServiceError(w, err, "failed to register device")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, device)
+}
+
+// UnregisterDevice godoc
+// @Summary Unregister a device
+// @Description Remove a push token of the authenticated user, e.g. on logout
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UnregisterDeviceRequest true "Device token"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/devices [delete]
+func (h *DeviceHandler) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UnregisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.deviceService.UnregisterDevice(r.Context(), userID, req.Token); err != nil {
+		respondServiceError(w, err, "failed to unregister device")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotificationSettings godoc
+// @Summary Get notification settings
+// @Description Get the push notification preferences of the authenticated user
+// @Tags devices
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} service.NotificationSettings
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/notification-settings [get]
+func (h *DeviceHandler) GetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := h.deviceService.GetNotificationSettings(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to get notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateNotificationSettings godoc
+// @Summary Update notification settings
+// @Description Replace the push notification preferences of the authenticated user. muted_until mutes all notifications until that time.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.NotificationSettings true "Notification settings"
+// @Success 200 {object} service.NotificationSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/notification-settings [put]
+func (h *DeviceHandler) UpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req service.NotificationSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := h.deviceService.UpdateNotificationSettings(r.Context(), userID, req)
+	if err != nil {
+		respondServiceError(w, err, "failed to update notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// Request types
+type RegisterDeviceRequest struct {
+	Platform string `json:"platform"` // ios, android or web
+	Token    string `json:"token"`
+}
+
+type UnregisterDeviceRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// maxDeviceTokenLength bounds the device tokens accepted from clients
+const maxDeviceTokenLength = 4096
+
+// DeviceResponse represents a registered device in the API response
+type DeviceResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotificationSettings are a user's push notification preferences
+type NotificationSettings struct {
+	MuteReactions bool       `json:"mute_reactions"`
+	MutePhotos    bool       `json:"mute_photos"`
+	MutedUntil    *time.Time `json:"muted_until"` // mutes everything until then
+}
+
+// DeviceService manages device tokens and notification settings
+type DeviceService struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+// NewDeviceService creates a new device service
+func NewDeviceService(pool *pgxpool.Pool, queries *db.Queries) *DeviceService {
+	return &DeviceService{
+		pool:    pool,
+		queries: queries,
+	}
+}
+
+// RegisterDevice stores a device token for userID. Registering a known token again
+// updates it, moving it to userID if another user had registered it.
+func (s *DeviceService) RegisterDevice(ctx context.Context, userID uuid.UUID, platform, token string) (*DeviceResponse, error) {
+	switch platform {
+	case PlatformIOS, PlatformAndroid, PlatformWeb:
+	default:
+		return nil, &ValidationError{Field: "platform", Message: "platform must be ios, android or web"}
+	}
+	if token == "" || len(token) > maxDeviceTokenLength {
+		return nil, &ValidationError{Field: "token", Message: "token is required and must be at most 4096 bytes"}
+	}
+
+	d, err := s.queries.UpsertDeviceToken(ctx, db.UpsertDeviceTokenParams{
+		UserID:   userID,
+		Platform: platform,
+		Token:    token,
+	})
+	if err != nil {
+		return nil, mapDBError(err, "device", "register device")
+	}
+
+	return &DeviceResponse{
+		ID:        d.ID,
+		Platform:  d.Platform,
+		Token:     d.Token,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}, nil
+}
+
+// UnregisterDevice removes one of userID's device tokens
+func (s *DeviceService) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	n, err := s.queries.DeleteDeviceToken(ctx, db.DeleteDeviceTokenParams{UserID: userID, Token: token})
+	if err != nil {
+		return mapDBError(err, "device", "unregister device")
+	}
+	if n == 0 {
+		return &NotFoundError{Resource: "device"}
+	}
+	return nil
+}
+
+// GetNotificationSettings returns userID's notification settings.
+// Users who never changed them get everything.
+func (s *DeviceService) GetNotificationSettings(ctx context.Context, userID uuid.UUID) (*NotificationSettings, error) {
+	settings, err := s.queries.GetNotificationSettings(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &NotificationSettings{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	return newNotificationSettings(settings), nil
+}
+
+// UpdateNotificationSettings replaces userID's notification settings
+func (s *DeviceService) UpdateNotificationSettings(ctx context.Context, userID uuid.UUID, settings NotificationSettings) (*NotificationSettings, error) {
+	updated, err := s.queries.UpsertNotificationSettings(ctx, db.UpsertNotificationSettingsParams{
+		UserID:        userID,
+		MuteReactions: settings.MuteReactions,
+		MutePhotos:    settings.MutePhotos,
+		MutedUntil:    settings.MutedUntil,
+	})
+	if err != nil {
+		return nil, mapDBError(err, "user", "update notification settings")
+	}
+	return newNotificationSettings(updated), nil
+}
+
+// newNotificationSettings converts a notification_settings row to its API response
+func newNotificationSettings(s db.NotificationSetting) *NotificationSettings {
+	return &NotificationSettings{
+		MuteReactions: s.MuteReactions,
+		MutePhotos:    s.MutePhotos,
+		MutedUntil:    s.MutedUntil,
+	}
+}
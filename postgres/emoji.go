@@ -0,0 +1,128 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxEmojiLength is the longest reaction accepted, in code points, matching reactions.emoji
+const MaxEmojiLength = 32
+
+const (
+	zeroWidthJoiner   = '\u200D'
+	variationSelector = '\uFE0F' // requests emoji presentation
+	combiningKeycap   = '\u20E3'
+	tagCancel         = '\U000E007F'
+)
+
+// extendedPictographic lists the Extended_Pictographic ranges of Unicode emoji-data.txt,
+// which cover every emoji as well as code points reserved for future ones
+var extendedPictographic = [][2]rune{
+	{0x00A9, 0x00A9}, {0x00AE, 0x00AE}, {0x203C, 0x203C}, {0x2049, 0x2049},
+	{0x2122, 0x2122}, {0x2139, 0x2139}, {0x2194, 0x2199}, {0x21A9, 0x21AA},
+	{0x231A, 0x231B}, {0x2328, 0x2328}, {0x2388, 0x2388}, {0x23CF, 0x23CF},
+	{0x23E9, 0x23F3}, {0x23F8, 0x23FA}, {0x24C2, 0x24C2}, {0x25AA, 0x25AB},
+	{0x25B6, 0x25B6}, {0x25C0, 0x25C0}, {0x25FB, 0x25FE}, {0x2600, 0x2605},
+	{0x2607, 0x2612}, {0x2614, 0x2685}, {0x2690, 0x2705}, {0x2708, 0x2712},
+	{0x2714, 0x2714}, {0x2716, 0x2716}, {0x271D, 0x271D}, {0x2721, 0x2721},
+	{0x2728, 0x2728}, {0x2733, 0x2734}, {0x2744, 0x2744}, {0x2747, 0x2747},
+	{0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757},
+	{0x2763, 0x2767}, {0x2795, 0x2797}, {0x27A1, 0x27A1}, {0x27B0, 0x27B0},
+	{0x27BF, 0x27BF}, {0x2934, 0x2935}, {0x2B05, 0x2B07}, {0x2B1B, 0x2B1C},
+	{0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x3030, 0x3030}, {0x303D, 0x303D},
+	{0x3297, 0x3297}, {0x3299, 0x3299}, {0x1F000, 0x1F0FF}, {0x1F10D, 0x1F10F},
+	{0x1F12F, 0x1F12F}, {0x1F16C, 0x1F171}, {0x1F17E, 0x1F17F}, {0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A}, {0x1F1AD, 0x1F1E5}, {0x1F201, 0x1F20F}, {0x1F21A, 0x1F21A},
+	{0x1F22F, 0x1F22F}, {0x1F232, 0x1F23A}, {0x1F23C, 0x1F23F}, {0x1F249, 0x1F3FA},
+	{0x1F400, 0x1F53D}, {0x1F546, 0x1F64F}, {0x1F680, 0x1F6FF}, {0x1F774, 0x1F77F},
+	{0x1F7D5, 0x1F7FF}, {0x1F80C, 0x1F80F}, {0x1F848, 0x1F84F}, {0x1F85A, 0x1F85F},
+	{0x1F888, 0x1F88F}, {0x1F8AE, 0x1F8FF}, {0x1F90C, 0x1F93A}, {0x1F93C, 0x1F945},
+	{0x1F947, 0x1FAFF}, {0x1FC00, 0x1FFFD},
+}
+
+func isPictographic(r rune) bool {
+	for _, rg := range extendedPictographic {
+		if r < rg[0] {
+			return false
+		}
+		if r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool { return r >= 0x1F1E6 && r <= 0x1F1FF }
+func isSkinTone(r rune) bool          { return r >= 0x1F3FB && r <= 0x1F3FF }
+func isTag(r rune) bool               { return r >= 0xE0020 && r <= 0xE007E }
+func isKeycapBase(r rune) bool        { return r == '#' || r == '*' || (r >= '0' && r <= '9') }
+
+// IsSingleEmoji reports whether s is exactly one emoji, i.e. one grapheme cluster
+// forming an emoji sequence as defined by Unicode Technical Standard #51: a single
+// emoji with an optional presentation selector or skin tone, a flag, a keycap,
+// a tag sequence such as the flag of England, or a ZWJ sequence of these.
+func IsSingleEmoji(s string) bool {
+	if s == "" || !utf8.ValidString(s) || utf8.RuneCountInString(s) > MaxEmojiLength {
+		return false
+	}
+	runes := []rune(s)
+
+	// Flags: exactly two regional indicators
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	// Keycaps: 1️⃣, #️⃣, *️⃣
+	if isKeycapBase(runes[0]) {
+		return len(runes) == 3 && runes[1] == variationSelector && runes[2] == combiningKeycap
+	}
+
+	// ZWJ sequence of elements; a lone element is the common case
+	i := 0
+	for {
+		next, ok := emojiElement(runes, i)
+		if !ok {
+			return false
+		}
+		i = next
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+}
+
+// emojiElement matches one element of a ZWJ sequence starting at runes[i]:
+// a pictographic code point followed by a presentation selector, a skin tone,
+// or a tag sequence. It returns the index after the element.
+func emojiElement(runes []rune, i int) (int, bool) {
+	if i >= len(runes) || !isPictographic(runes[i]) {
+		return i, false
+	}
+	i++
+	if i == len(runes) {
+		return i, true
+	}
+
+	switch r := runes[i]; {
+	case r == variationSelector, isSkinTone(r):
+		return i + 1, true
+	case isTag(r):
+		for i < len(runes) && isTag(runes[i]) {
+			i++
+		}
+		if i == len(runes) || runes[i] != tagCancel {
+			return i, false
+		}
+		return i + 1, true
+	}
+	return i, true
+}
+
+// normalizeEmoji drops presentation selectors so that "❤" and "❤️" compare equal
+func normalizeEmoji(s string) string {
+	return strings.ReplaceAll(s, string(variationSelector), "")
+}
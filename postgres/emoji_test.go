@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsSingleEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		emoji string
+		want  bool
+	}{
+		{"emoji", "😂", true},
+		{"text presentation", "❤", true},
+		{"emoji presentation", "❤️", true},
+		{"symbol with presentation selector", "©️", true},
+		{"skin tone", "👍🏽", true},
+		{"flag", "🇻🇳", true},
+		{"keycap", "1️⃣", true},
+		{"tag sequence", "🏴󠁧󠁢󠁥󠁮󠁧󠁿", true},
+		{"zwj family", "👨‍👩‍👧‍👦", true},
+		{"zwj with presentation selector", "🏳️‍🌈", true},
+		{"zwj with skin tones", "🧑🏻‍🤝‍🧑🏼", true},
+		{"zwj kiss", "👩‍❤️‍💋‍👨", true},
+
+		{"empty", "", false},
+		{"letter", "a", false},
+		{"digit", "1", false},
+		{"two emoji", "😂😂", false},
+		{"two emoji with presentation selectors", "❤️❤️", false},
+		{"emoji and text", "❤️x", false},
+		{"text and emoji", "ok👍", false},
+		{"emoji and space", "👍 ", false},
+		{"lone regional indicator", "🇻", false},
+		{"three regional indicators", "🇻🇳🇺", false},
+		{"lone skin tone", "🏻", false},
+		{"trailing joiner", "👍‍", false},
+		{"leading joiner", "‍👍", false},
+		{"joined text", "👍‍x", false},
+		{"unterminated tag sequence", "🏴󠁧󠁢", false},
+		{"invalid utf-8", "\xff", false},
+		{"too long", strings.Repeat("👨‍", MaxEmojiLength/2) + "👨", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSingleEmoji(tt.emoji); got != tt.want {
+				t.Fatalf("IsSingleEmoji(%q) = %v, want %v", tt.emoji, got, tt.want)
+			}
+		})
+	}
+}
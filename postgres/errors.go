@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentinel errors returned (possibly wrapped) by the service layer.
+// Match them with errors.Is.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+	ErrExpired    = errors.New("expired")
+)
+
+// NotFoundError reports that a resource does not exist (or is not visible to the caller)
+type NotFoundError struct {
+	Resource string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Resource + " not found"
+}
+
+// Is makes errors.Is(err, ErrNotFound) match
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ValidationError reports an invalid input value
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// Is makes errors.Is(err, ErrValidation) match
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// PostgreSQL error codes we translate into domain errors
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+)
+
+// mapDBError translates database errors into domain errors.
+// resource names the entity the query was about; op describes the failed operation.
+func mapDBError(err error, resource, op string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &NotFoundError{Resource: resource}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			return fmt.Errorf("%w: %s already exists", ErrConflict, resource)
+		case pgForeignKeyViolation:
+			// The referenced row (e.g. the photo of a reaction) does not exist
+			return &NotFoundError{Resource: referencedResource(pgErr.ConstraintName, resource)}
+		case pgCheckViolation:
+			// The database message quotes the constraint and the offending row
+			return checkViolation(pgErr.ConstraintName, resource)
+		case pgStringTooLong:
+			return &ValidationError{Message: resource + " has a value that is too long"}
+		}
+	}
+
+	return fmt.Errorf("failed to %s: %w", op, err)
+}
+
+// referencedResource names the row a foreign key constraint points at
+func referencedResource(constraint, fallback string) string {
+	switch constraint {
+	case "reactions_photo_id_fkey", "notifications_photo_id_fkey", "photo_replies_photo_id_fkey":
+		return "photo"
+	case "reactions_user_id_fkey", "photos_sender_id_fkey",
+		"friendships_requester_id_fkey", "friendships_addressee_id_fkey",
+		"photo_recipients_recipient_id_fkey", "device_tokens_user_id_fkey",
+		"notification_settings_user_id_fkey", "notifications_user_id_fkey",
+		"notifications_actor_id_fkey", "photo_replies_thread_user_id_fkey",
+		"photo_replies_author_id_fkey", "widget_settings_user_id_fkey",
+		"photo_uploads_sender_id_fkey", "resumable_uploads_sender_id_fkey":
+		return "user"
+	}
+	return fallback
+}
+
+// checkViolations are the messages of the schema's CHECK constraints
+var checkViolations = map[string]ValidationError{
+	"friendships_not_self":           {Field: "user_id", Message: "cannot befriend yourself"},
+	"friendships_status_check":       {Field: "status", Message: "invalid friendship status"},
+	"device_tokens_platform_check":   {Field: "platform", Message: "platform must be ios, android or web"},
+	"notifications_kind_check":       {Field: "kind", Message: "invalid notification kind"},
+	"photo_replies_body_check":       {Field: "body", Message: "reply must be 1 to 1000 characters"},
+	"widget_settings_size_check":     {Field: "size", Message: "size must be small, medium or large"},
+	"resumable_uploads_offset_check": {Field: "offset", Message: "offset is outside the upload"},
+}
+
+// checkViolation describes a violated CHECK constraint without exposing the database message
+func checkViolation(constraint, resource string) *ValidationError {
+	if v, ok := checkViolations[constraint]; ok {
+		return &v
+	}
+	return &ValidationError{Message: "invalid " + resource}
+}
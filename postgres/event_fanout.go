@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+const (
+	// EventChannel is the Postgres NOTIFY channel events are broadcast on
+	EventChannel = "photo_events"
+	// maxNotifyPayload stays under the 8000 byte limit of a NOTIFY payload
+	maxNotifyPayload = 7900
+	// maxListenBackoff caps the delay between listener reconnects
+	maxListenBackoff = 30 * time.Second
+)
+
+// wireEvent is an Event as broadcast between instances.
+// Unlike the client format it carries the audience of the event.
+type wireEvent struct {
+	Event
+	RecipientIDs []uuid.UUID `json:"recipient_ids,omitempty"`
+	// AudienceOmitted is set when the recipients did not fit in the payload;
+	// the listener loads them from the database instead
+	AudienceOmitted bool `json:"audience_omitted,omitempty"`
+}
+
+// PGEventPublisher broadcasts events to every instance with Postgres NOTIFY.
+// Each instance runs an EventListener that delivers them to its local hub.
+type PGEventPublisher struct {
+	queries *db.Queries
+}
+
+// NewPGEventPublisher creates a publisher that notifies EventChannel
+func NewPGEventPublisher(queries *db.Queries) *PGEventPublisher {
+	return &PGEventPublisher{
+		queries: queries,
+	}
+}
+
+// Publish sends the event with pg_notify. Failures are logged: the change itself has been saved.
+func (p *PGEventPublisher) Publish(ctx context.Context, e Event) {
+	payload, err := encodeWireEvent(e)
+	if err != nil {
+		log.Printf("failed to encode %s event for photo %s: %v", e.Type, e.PhotoID, err)
+		return
+	}
+	err = p.queries.NotifyEvent(ctx, db.NotifyEventParams{Channel: EventChannel, Payload: payload})
+	if err != nil {
+		log.Printf("failed to notify %s event for photo %s: %v", e.Type, e.PhotoID, err)
+	}
+}
+
+// encodeWireEvent marshals an event for NOTIFY, leaving out the recipients
+// and then the photo body if the payload would be too large
+func encodeWireEvent(e Event) (string, error) {
+	w := wireEvent{Event: e, RecipientIDs: e.RecipientIDs}
+	for {
+		b, err := json.Marshal(w)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(b) <= maxNotifyPayload:
+			return string(b), nil
+		case !w.AudienceOmitted:
+			w.RecipientIDs, w.AudienceOmitted = nil, true
+		case w.Photo != nil:
+			// Clients fetch the photo when the event has no body
+			w.Photo = nil
+		default:
+			return "", fmt.Errorf("payload of %d bytes is too large", len(b))
+		}
+	}
+}
+
+// EventListener receives the events broadcast on EventChannel and publishes them
+// to the local hub, so that clients connected to any instance see every event
+type EventListener struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+	local   EventPublisher
+}
+
+// NewEventListener creates a listener that forwards broadcast events to local
+func NewEventListener(pool *pgxpool.Pool, queries *db.Queries, local EventPublisher) *EventListener {
+	return &EventListener{
+		pool:    pool,
+		queries: queries,
+		local:   local,
+	}
+}
+
+// Run listens until ctx is canceled, reconnecting with backoff when the connection fails.
+// Events broadcast while it is disconnected are lost.
+func (l *EventListener) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		log.Printf("event listener: %v; reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxListenBackoff)
+	}
+}
+
+// listen holds a dedicated connection in LISTEN mode and dispatches notifications
+// until it fails. connected reports whether LISTEN succeeded.
+func (l *EventListener) listen(ctx context.Context) (connected bool, err error) {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	// A listening connection cannot be shared, so take it out of the pool for good
+	conn := pooled.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+EventChannel); err != nil {
+		return false, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		l.dispatch(ctx, n.Payload)
+	}
+}
+
+// dispatch decodes a notification and publishes it locally
+func (l *EventListener) dispatch(ctx context.Context, payload string) {
+	var w wireEvent
+	if err := json.Unmarshal([]byte(payload), &w); err != nil {
+		log.Printf("event listener: invalid payload: %v", err)
+		return
+	}
+
+	e := w.Event
+	e.RecipientIDs = w.RecipientIDs
+	if w.AudienceOmitted {
+		audience, err := l.queries.GetPhotoAudience(ctx, e.PhotoID)
+		if err != nil {
+			log.Printf("event listener: failed to load audience of photo %s: %v", e.PhotoID, err)
+			return
+		}
+		e.RecipientIDs = audience.RecipientIds
+	}
+	l.local.Publish(ctx, e)
+}
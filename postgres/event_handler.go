@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+const (
+	// wsWriteWait is the time allowed to write a message to the client
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is the time allowed to read the next pong from the client
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be shorter than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+	// sseKeepAlive is how often an idle event stream sends a comment to keep proxies from closing it
+	sseKeepAlive = 25 * time.Second
+	// sseRetry is the reconnect delay suggested to SSE clients, in milliseconds
+	sseRetry = 3000
+)
+
+// sseResetEvent tells an SSE client that events were lost and it should reload its state
+const sseResetEvent = "stream.reset"
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Connections authenticate with a bearer token rather than cookies,
+	// so cross-origin pages cannot ride on a user's session
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type EventHandler struct {
+	hub *service.EventHub
+}
+
+func NewEventHandler(hub *service.EventHub) *EventHandler {
+	return &EventHandler{
+		hub: hub,
+	}
+}
+
+// ServeWebSocket godoc
+// @Summary Stream photo and reaction events over WebSocket
+// @Description Upgrade to a WebSocket that receives photo.created, reaction.added and reaction.removed events as JSON messages.
+// @Description Browsers can pass the token in the access_token query parameter.
+// @Tags events
+// @Security BearerAuth
+// @Param subscribe query string false "Comma-separated topics: own (photos you sent), received (photos sent to you)" default(own,received)
+// @Param access_token query string false "Bearer token, for clients that cannot set headers"
+// @Success 101
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /ws [get]
+func (h *EventHandler) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseSubscriptionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already responded to the client
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(userID, filter)
+	defer sub.Close()
+
+	// Clients only send control frames; reading processes pongs and notices disconnects
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// Dropped by the hub for falling behind; the client should reconnect
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamEvents godoc
+// @Summary Stream photo and reaction events over Server-Sent Events
+// @Description Stream the same events as the WebSocket endpoint as text/event-stream, for clients that cannot hold WebSockets.
+// @Description Reconnect with Last-Event-ID to replay missed events. If some of them are no longer available,
+// @Description a stream.reset event is sent first and the client should reload its state.
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param subscribe query string false "Comma-separated topics: own (photos you sent), received (photos sent to you)" default(own,received)
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Param last_event_id query string false "Same as Last-Event-ID, for clients that cannot set headers"
+// @Param access_token query string false "Bearer token, for clients that cannot set headers"
+// @Success 200
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /me/events [get]
+func (h *EventHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseSubscriptionFilter(w, r)
+	if !ok {
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var sub *service.Subscription
+	var missed []service.Event
+	complete := true
+	if lastEventID == "" {
+		sub = h.hub.Subscribe(userID, filter)
+	} else {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		sub, missed, complete = h.hub.Resume(userID, filter, id)
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", sseResetEvent)
+	}
+	for _, event := range missed {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped by the hub for falling behind; the client reconnects and resumes
+				return
+			}
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeSSE writes one event in text/event-stream format
+func writeSSE(w http.ResponseWriter, event service.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// parseSubscriptionFilter reads the subscribe query parameter, responding 400 if it is invalid.
+// Without it the caller subscribes to all topics.
+func parseSubscriptionFilter(w http.ResponseWriter, r *http.Request) (service.SubscriptionFilter, bool) {
+	topics := r.URL.Query().Get("subscribe")
+	if topics == "" {
+		return service.SubscriptionFilter{Own: true, Received: true}, true
+	}
+
+	var filter service.SubscriptionFilter
+	for _, topic := range strings.Split(topics, ",") {
+		switch strings.TrimSpace(topic) {
+		case "own":
+			filter.Own = true
+		case "received":
+			filter.Received = true
+		default:
+			respondError(w, http.StatusBadRequest, "unknown topic "+topic)
+			return filter, false
+		}
+	}
+	return filter, true
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// subscriptionBuffer is the number of events queued for a subscriber before it is dropped
+	subscriptionBuffer = 64
+	// eventLogSize is the number of recent events kept per user for resuming streams
+	eventLogSize = 256
+	// eventLogTTL is how long the log of a user without new events is kept
+	eventLogTTL = 15 * time.Minute
+	// eventLogSweepEvery is the number of published events between sweeps of idle logs
+	eventLogSweepEvery = 1024
+)
+
+// SubscriptionFilter selects the events a subscriber receives
+type SubscriptionFilter struct {
+	Own      bool // events about photos the subscriber sent
+	Received bool // events about photos sent to the subscriber
+}
+
+// Subscription is one subscriber's stream of events from an EventHub
+type Subscription struct {
+	UserID uuid.UUID
+	Filter SubscriptionFilter
+
+	hub    *EventHub
+	events chan Event
+	once   sync.Once
+}
+
+// Events returns the subscriber's events. The channel is closed when the
+// subscription is closed, or when the subscriber falls too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes from the hub
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// matches reports whether the subscription wants an event
+func (s *Subscription) matches(e Event) bool {
+	if s.Filter.Own && e.SenderID == s.UserID {
+		return true
+	}
+	if s.Filter.Received {
+		for _, id := range e.RecipientIDs {
+			if id == s.UserID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// eventLog is a ring buffer of the most recent events of one user, in the order
+// they were published
+type eventLog struct {
+	events []Event
+	next   int // index of the oldest event once the buffer is full
+	lastAt time.Time
+}
+
+func (l *eventLog) append(e Event) {
+	if len(l.events) < eventLogSize {
+		l.events = append(l.events, e)
+	} else {
+		l.events[l.next] = e
+		l.next = (l.next + 1) % eventLogSize
+	}
+	l.lastAt = time.Now()
+}
+
+// since returns the events logged after the one with lastID, oldest first.
+// Events are logged in the order they are published rather than by ID, since
+// transactions commit out of ID order. If lastID is no longer in the log,
+// complete is false and the events with a greater ID are returned instead.
+func (l *eventLog) since(lastID int64) (events []Event, complete bool) {
+	for i := range l.events {
+		e := l.events[(l.next+i)%len(l.events)]
+		if complete {
+			events = append(events, e)
+		} else if e.ID == lastID {
+			complete, events = true, nil
+		} else if e.ID > lastID {
+			events = append(events, e)
+		}
+	}
+	return events, complete
+}
+
+// EventHub fans events out to the subscribers connected to this process.
+// It keeps a bounded log per user so that disconnected subscribers can resume
+// where they left off. Event IDs are assigned by the outbox, so a subscriber
+// can resume on any instance that received the same events.
+type EventHub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[*Subscription]struct{}
+	logs map[uuid.UUID]*eventLog
+	// published counts events, to sweep idle logs every eventLogSweepEvery
+	published int64
+}
+
+// NewEventHub creates an empty event hub
+func NewEventHub() *EventHub {
+	return &EventHub{
+		subs: make(map[uuid.UUID]map[*Subscription]struct{}),
+		logs: make(map[uuid.UUID]*eventLog),
+	}
+}
+
+// Subscribe starts delivering the events that match filter to userID
+func (h *EventHub) Subscribe(userID uuid.UUID, filter SubscriptionFilter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.add(userID, filter)
+}
+
+// Resume subscribes like Subscribe and also returns the logged events after
+// lastEventID that match filter, oldest first. complete is false if lastEventID
+// is no longer in the log (or never was, e.g. it was received before this
+// instance started), in which case the caller should reload its state rather
+// than rely on the replay.
+func (h *EventHub) Resume(userID uuid.UUID, filter SubscriptionFilter, lastEventID int64) (sub *Subscription, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = h.add(userID, filter)
+	if userLog := h.logs[userID]; userLog != nil {
+		var logged []Event
+		logged, complete = userLog.since(lastEventID)
+		for _, e := range logged {
+			if sub.matches(e) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	return sub, missed, complete
+}
+
+// Publish logs an event for its sender and recipients and delivers it
+// to their matching subscribers. Subscribers whose buffer is full are dropped
+// rather than blocking the publisher.
+func (h *EventHub) Publish(_ context.Context, e Event) {
+	var slow []*Subscription
+
+	h.mu.Lock()
+	h.published++
+	for _, userID := range append([]uuid.UUID{e.SenderID}, e.RecipientIDs...) {
+		userLog := h.logs[userID]
+		if userLog == nil {
+			userLog = &eventLog{}
+			h.logs[userID] = userLog
+		}
+		userLog.append(e)
+
+		for sub := range h.subs[userID] {
+			if !sub.matches(e) {
+				continue
+			}
+			select {
+			case sub.events <- e:
+			default:
+				slow = append(slow, sub)
+			}
+		}
+	}
+	if h.published%eventLogSweepEvery == 0 {
+		h.sweepLogs()
+	}
+	h.mu.Unlock()
+
+	for _, sub := range slow {
+		h.remove(sub)
+	}
+}
+
+// add registers a subscription. The caller must hold h.mu.
+func (h *EventHub) add(userID uuid.UUID, filter SubscriptionFilter) *Subscription {
+	sub := &Subscription{
+		UserID: userID,
+		Filter: filter,
+		hub:    h,
+		events: make(chan Event, subscriptionBuffer),
+	}
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+// sweepLogs drops the logs of users without recent events. The caller must hold h.mu.
+func (h *EventHub) sweepLogs() {
+	cutoff := time.Now().Add(-eventLogTTL)
+	for userID, userLog := range h.logs {
+		if userLog.lastAt.Before(cutoff) {
+			delete(h.logs, userID)
+		}
+	}
+}
+
+// remove unregisters a subscription and closes its channel
+func (h *EventHub) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[sub.UserID], sub)
+		if len(h.subs[sub.UserID]) == 0 {
+			delete(h.subs, sub.UserID)
+		}
+		close(sub.events)
+	})
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+type FriendshipHandler struct {
+	friendshipService *service.FriendshipService
+}
+
+func NewFriendshipHandler(friendshipService *service.FriendshipService) *FriendshipHandler {
+	return &FriendshipHandler{
+		friendshipService: friendshipService,
+	}
+}
+
+// ListFriends godoc
+// @Summary List friends
+// @Description Get the accepted friends of the authenticated user
+// @Tags friends
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} service.FriendResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/friends [get]
+func (h *FriendshipHandler) ListFriends(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	friends, err := h.friendshipService.ListFriends(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to list friends")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, friends)
+}
+
+// ListFriendRequests godoc
+// @Summary List incoming friend requests
+// @Description Get the pending friend requests sent to the authenticated user
+// @Tags friends
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} service.FriendshipResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/friends/requests [get]
+func (h *FriendshipHandler) ListFriendRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := h.friendshipService.ListIncomingRequests(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to list friend requests")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, requests)
+}
+
+// SendFriendRequest godoc
+// @Summary Send a friend request
+// @Description Ask another user to be friends. If they already asked, their request is accepted.
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UserIDRequest true "Target user"
+// @Success 201 {object} service.FriendshipResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/friends/requests [post]
+func (h *FriendshipHandler) SendFriendRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, ok := decodeUserIDRequest(w, r)
+	if !ok {
+		return
+	}
+
+	friendship, err := h.friendshipService.RequestFriendship(r.Context(), userID, targetID)
+	if err != nil {
+		respondServiceError(w, err, "failed to send friend request")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, friendship)
+}
+
+// AcceptFriendRequest godoc
+// @Summary Accept a friend request
+// @Description Accept the pending friend request sent by another user
+// @Tags friends
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Requester ID"
+// @Success 200 {object} service.FriendshipResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/friends/requests/{user_id}/accept [post]
+func (h *FriendshipHandler) AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerFriendRequest(w, r, h.friendshipService.AcceptFriendship)
+}
+
+// DeclineFriendRequest godoc
+// @Summary Decline a friend request
+// @Description Decline the pending friend request sent by another user
+// @Tags friends
+// @Produce json
+// @Security BearerAuth
+// @Param user_id path string true "Requester ID"
+// @Success 200 {object} service.FriendshipResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/friends/requests/{user_id}/decline [post]
+func (h *FriendshipHandler) DeclineFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.answerFriendRequest(w, r, h.friendshipService.DeclineFriendship)
+}
+
+// answerFriendRequest handles accepting or declining the request from the {user_id} path variable
+func (h *FriendshipHandler) answerFriendRequest(w http.ResponseWriter, r *http.Request,
+	answer func(ctx context.Context, userID, requesterID uuid.UUID) (*service.FriendshipResponse, error)) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	requesterID, err := uuid.Parse(mux.Vars(r)["user_id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	friendship, err := answer(r.Context(), userID, requesterID)
+	if err != nil {
+		respondServiceError(w, err, "failed to answer friend request")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, friendship)
+}
+
+// BlockUser godoc
+// @Summary Block a user
+// @Description Block another user. This ends any friendship and prevents new requests.
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UserIDRequest true "User to block"
+// @Success 201 {object} service.FriendshipResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/blocks [post]
+func (h *FriendshipHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, ok := decodeUserIDRequest(w, r)
+	if !ok {
+		return
+	}
+
+	friendship, err := h.friendshipService.BlockUser(r.Context(), userID, targetID)
+	if err != nil {
+		respondServiceError(w, err, "failed to block user")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, friendship)
+}
+
+// Request types
+type UserIDRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// decodeUserIDRequest reads a UserIDRequest body, responding 400 if it is invalid
+func decodeUserIDRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	var req UserIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid user ID")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// Friendship statuses stored in friendships.status
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+	FriendshipDeclined = "declined"
+	FriendshipBlocked  = "blocked"
+)
+
+// FriendshipResponse represents the relationship between two users in the API response
+type FriendshipResponse struct {
+	RequesterID uuid.UUID `json:"requester_id"`
+	AddresseeID uuid.UUID `json:"addressee_id"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FriendResponse represents an accepted friend in the API response
+type FriendResponse struct {
+	UserID       uuid.UUID `json:"user_id"`
+	FriendsSince time.Time `json:"friends_since"`
+}
+
+// FriendshipService handles the friendship graph
+type FriendshipService struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+// NewFriendshipService creates a new friendship service
+func NewFriendshipService(pool *pgxpool.Pool, queries *db.Queries) *FriendshipService {
+	return &FriendshipService{
+		pool:    pool,
+		queries: queries,
+	}
+}
+
+// RequestFriendship sends a friend request from userID to targetID.
+// If targetID already asked userID, the pending request is accepted instead.
+func (s *FriendshipService) RequestFriendship(ctx context.Context, userID, targetID uuid.UUID) (*FriendshipResponse, error) {
+	if userID == targetID {
+		return nil, &ValidationError{Field: "user_id", Message: "cannot befriend yourself"}
+	}
+
+	var result db.Friendship
+	err := withTx(ctx, s.pool, s.queries, func(q *db.Queries) error {
+		existing, err := q.GetFriendship(ctx, db.GetFriendshipParams{UserA: userID, UserB: targetID})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return mapDBError(err, "friendship", "get friendship")
+		}
+
+		if err == nil {
+			switch existing.Status {
+			case FriendshipBlocked:
+				return fmt.Errorf("%w: cannot send a friend request to this user", ErrForbidden)
+			case FriendshipAccepted:
+				return fmt.Errorf("%w: already friends", ErrConflict)
+			case FriendshipPending:
+				if existing.RequesterID == userID {
+					return fmt.Errorf("%w: friend request already sent", ErrConflict)
+				}
+				// They asked first: a request in the other direction accepts theirs
+				result, err = q.UpdateFriendshipStatus(ctx, db.UpdateFriendshipStatusParams{
+					NewStatus:     FriendshipAccepted,
+					RequesterID:   targetID,
+					AddresseeID:   userID,
+					CurrentStatus: FriendshipPending,
+				})
+				if err != nil {
+					return mapDBError(err, "friend request", "accept friend request")
+				}
+				return nil
+			}
+
+			// A declined request may be sent again, by either user
+			if err := q.DeleteFriendship(ctx, db.DeleteFriendshipParams{UserA: userID, UserB: targetID}); err != nil {
+				return mapDBError(err, "friendship", "delete friendship")
+			}
+		}
+
+		result, err = q.CreateFriendship(ctx, db.CreateFriendshipParams{
+			RequesterID: userID,
+			AddresseeID: targetID,
+			Status:      FriendshipPending,
+		})
+		if err != nil {
+			return mapDBError(err, "user", "create friend request")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newFriendshipResponse(result), nil
+}
+
+// AcceptFriendship accepts the pending friend request requesterID sent to userID
+func (s *FriendshipService) AcceptFriendship(ctx context.Context, userID, requesterID uuid.UUID) (*FriendshipResponse, error) {
+	return s.answerRequest(ctx, userID, requesterID, FriendshipAccepted)
+}
+
+// DeclineFriendship declines the pending friend request requesterID sent to userID
+func (s *FriendshipService) DeclineFriendship(ctx context.Context, userID, requesterID uuid.UUID) (*FriendshipResponse, error) {
+	return s.answerRequest(ctx, userID, requesterID, FriendshipDeclined)
+}
+
+// answerRequest moves a pending request addressed to userID to the given status
+func (s *FriendshipService) answerRequest(ctx context.Context, userID, requesterID uuid.UUID, status string) (*FriendshipResponse, error) {
+	f, err := s.queries.UpdateFriendshipStatus(ctx, db.UpdateFriendshipStatusParams{
+		NewStatus:     status,
+		RequesterID:   requesterID,
+		AddresseeID:   userID,
+		CurrentStatus: FriendshipPending,
+	})
+	if err != nil {
+		return nil, mapDBError(err, "friend request", "update friend request")
+	}
+	return newFriendshipResponse(f), nil
+}
+
+// BlockUser blocks targetID for userID, replacing any existing relationship between them.
+// Blocked users are no longer friends and cannot send new requests.
+func (s *FriendshipService) BlockUser(ctx context.Context, userID, targetID uuid.UUID) (*FriendshipResponse, error) {
+	if userID == targetID {
+		return nil, &ValidationError{Field: "user_id", Message: "cannot block yourself"}
+	}
+
+	var result db.Friendship
+	err := withTx(ctx, s.pool, s.queries, func(q *db.Queries) error {
+		existing, err := q.GetFriendship(ctx, db.GetFriendshipParams{UserA: userID, UserB: targetID})
+		if err == nil && existing.Status == FriendshipBlocked && existing.RequesterID == targetID {
+			// Already blocked by the other user; keep their block in place
+			result = existing
+			return nil
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return mapDBError(err, "friendship", "get friendship")
+		}
+
+		if err := q.DeleteFriendship(ctx, db.DeleteFriendshipParams{UserA: userID, UserB: targetID}); err != nil {
+			return mapDBError(err, "friendship", "delete friendship")
+		}
+		result, err = q.CreateFriendship(ctx, db.CreateFriendshipParams{
+			RequesterID: userID,
+			AddresseeID: targetID,
+			Status:      FriendshipBlocked,
+		})
+		if err != nil {
+			return mapDBError(err, "user", "block user")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newFriendshipResponse(result), nil
+}
+
+// ListFriends returns the accepted friends of a user, most recent first
+func (s *FriendshipService) ListFriends(ctx context.Context, userID uuid.UUID) ([]FriendResponse, error) {
+	rows, err := s.queries.ListFriends(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list friends: %w", err)
+	}
+
+	friends := make([]FriendResponse, 0, len(rows))
+	for _, row := range rows {
+		friends = append(friends, FriendResponse{
+			UserID:       row.FriendID,
+			FriendsSince: row.FriendsSince,
+		})
+	}
+	return friends, nil
+}
+
+// ListIncomingRequests returns the pending friend requests sent to a user
+func (s *FriendshipService) ListIncomingRequests(ctx context.Context, userID uuid.UUID) ([]FriendshipResponse, error) {
+	rows, err := s.queries.ListIncomingFriendRequests(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list friend requests: %w", err)
+	}
+
+	requests := make([]FriendshipResponse, 0, len(rows))
+	for _, row := range rows {
+		requests = append(requests, *newFriendshipResponse(row))
+	}
+	return requests, nil
+}
+
+// newFriendshipResponse converts a friendship row to its API response
+func newFriendshipResponse(f db.Friendship) *FriendshipResponse {
+	return &FriendshipResponse{
+		RequesterID: f.RequesterID,
+		AddresseeID: f.AddresseeID,
+		Status:      f.Status,
+		CreatedAt:   f.CreatedAt,
+		UpdatedAt:   f.UpdatedAt,
+	}
+}
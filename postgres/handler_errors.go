@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// Machine-readable error codes returned in ErrorResponse
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeForbidden        = "forbidden"
+	CodeExpired          = "expired"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeInternal         = "internal_error"
+)
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes what went wrong
+type ErrorBody struct {
+	Code    string `json:"code"`            // stable, machine-readable
+	Message string `json:"message"`         // human-readable, may change
+	Field   string `json:"field,omitempty"` // the offending input, for validation errors
+}
+
+// respondError writes an error with the default code for the status
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, ErrorResponse{Error: ErrorBody{
+		Code:    codeForStatus(status),
+		Message: message,
+	}})
+}
+
+// respondServiceError translates an error returned by the service layer into an HTTP response.
+// Unexpected errors are logged and reported as a 500 with the given message,
+// so internal details never leak to clients.
+func respondServiceError(w http.ResponseWriter, err error, message string) {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		respondJSON(w, http.StatusBadRequest, ErrorResponse{Error: ErrorBody{
+			Code:    CodeValidation,
+			Message: validationErr.Message,
+			Field:   validationErr.Field,
+		}})
+	case errors.Is(err, service.ErrNotFound):
+		respondError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		respondError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		respondError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrExpired):
+		respondError(w, http.StatusGone, err.Error())
+	default:
+		log.Printf("%s: %v", message, err)
+		respondError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// codeForStatus returns the default error code for an HTTP status
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusGone:
+		return CodeExpired
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,760 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPhotoPixels is the largest image accepted (width times height, 40 megapixels),
+// so decoding a small but highly compressed file cannot exhaust memory. It is
+// checked against the header before any image is decoded.
+const MaxPhotoPixels = 40_000_000
+
+// photoInfo is what inspecting a photo's content verified about it
+type photoInfo struct {
+	MimeType string // sniffed from the content, whatever the client declared
+	// Width and Height are the displayed dimensions, after applying Orientation
+	Width, Height int
+	// Orientation is the EXIF orientation, from 1 (upright) to 8
+	Orientation int
+	// Data is the content with location and device metadata removed
+	Data []byte
+}
+
+// errMalformedImage is returned for content that looks like a supported image
+// format but cannot be parsed
+var errMalformedImage = errors.New("malformed image")
+
+// inspectPhoto checks that data is a supported image within the size limits,
+// reads its dimensions and orientation and removes GPS and device metadata
+func inspectPhoto(data []byte) (*photoInfo, error) {
+	if len(data) == 0 {
+		return nil, &ValidationError{Field: "photo", Message: "photo is empty"}
+	}
+	if len(data) > MaxPhotoSize {
+		return nil, &ValidationError{Field: "photo", Message: fmt.Sprintf("photo exceeds %d bytes", MaxPhotoSize)}
+	}
+
+	mimeType := sniffPhotoType(data)
+	var info *photoInfo
+	var err error
+	switch mimeType {
+	case "image/jpeg":
+		info, err = inspectJPEG(data)
+	case "image/png":
+		info, err = inspectPNG(data)
+	case "image/gif":
+		info, err = inspectGIF(data)
+	case "image/webp":
+		info, err = inspectWebP(data)
+	case "image/heic":
+		info, err = inspectHEIC(data)
+	default:
+		return nil, &ValidationError{Field: "photo", Message: "content is not a supported image"}
+	}
+	if err != nil {
+		return nil, &ValidationError{Field: "photo", Message: fmt.Sprintf("invalid %s content: %v", mimeType, err)}
+	}
+
+	if info.Width <= 0 || info.Height <= 0 {
+		return nil, &ValidationError{Field: "photo", Message: "photo has no dimensions"}
+	}
+	if int64(info.Width)*int64(info.Height) > MaxPhotoPixels {
+		return nil, &ValidationError{Field: "photo", Message: fmt.Sprintf("photo exceeds %d pixels", MaxPhotoPixels)}
+	}
+	if info.Orientation >= 5 {
+		// Orientations 5 to 8 turn the image by 90 degrees
+		info.Width, info.Height = info.Height, info.Width
+	}
+	info.MimeType = mimeType
+	return info, nil
+}
+
+// inspectUpload inspects the content of an upload declared as mimeType. The
+// content must be of the declared type, which the upload's key was derived from.
+func inspectUpload(data []byte, mimeType string) (*photoInfo, error) {
+	info, err := inspectPhoto(data)
+	if err != nil {
+		return nil, err
+	}
+	if info.MimeType != mimeType {
+		return nil, &ValidationError{Field: "mime_type", Message: fmt.Sprintf("content is %s, declared %s", info.MimeType, mimeType)}
+	}
+	return info, nil
+}
+
+// sniffPhotoType returns the supported mime type of data, or "" if it is not a supported image
+func sniffPhotoType(data []byte) string {
+	if isHEIC(data) {
+		return "image/heic"
+	}
+	mimeType := http.DetectContentType(data)
+	if _, ok := photoExtensions[mimeType]; !ok {
+		return ""
+	}
+	return mimeType
+}
+
+// isHEIC reports whether data starts with a file type box naming a HEIC brand
+func isHEIC(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		return false
+	}
+	// Major brand, minor version, then compatible brands
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue
+		}
+		switch string(data[i : i+4]) {
+		case "heic", "heix", "heim", "heis":
+			return true
+		}
+	}
+	return false
+}
+
+// inspectJPEG reads a JPEG's dimensions and removes its EXIF, XMP, IPTC, comment and
+// multi-picture segments. The file is cut at the primary image's end, dropping the
+// secondary images (e.g. MPF previews, which carry their own EXIF) and trailers that
+// follow it. A non-default orientation is kept in a minimal EXIF segment.
+func inspectJPEG(data []byte) (*photoInfo, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...) // SOI
+	exifAt := len(out)             // after SOI and the JFIF segment, if any
+	pos := 2
+	for pos < len(data) {
+		if pos+2 > len(data) || data[pos] != 0xFF {
+			return nil, errMalformedImage
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF: // fill byte
+			pos++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7: // standalone markers
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		case marker == 0xD9: // end of image: drop anything after it
+			out = append(out, data[pos:pos+2]...)
+			pos = len(data)
+			continue
+		}
+
+		if pos+4 > len(data) {
+			return nil, errMalformedImage
+		}
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end < pos+4 || end > len(data) {
+			return nil, errMalformedImage
+		}
+		payload := data[pos+4 : end]
+		switch {
+		case marker == 0xE1: // EXIF or XMP
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(payload[6:])
+			}
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte("MPF\x00")): // index of the secondary images
+		case marker == 0xED || marker == 0xFE: // IPTC, comment
+		default:
+			out = append(out, data[pos:end]...)
+			if marker == 0xE0 && exifAt == len(out)-(end-pos) {
+				exifAt = len(out)
+			}
+		}
+		if marker == 0xDA { // start of scan: copy its entropy-coded data
+			scanEnd := jpegScanEnd(data, end)
+			out = append(out, data[end:scanEnd]...)
+			end = scanEnd
+		}
+		pos = end
+	}
+
+	if orientation != 1 {
+		exif := append([]byte("Exif\x00\x00"), orientationEXIF(orientation)...)
+		segment := []byte{0xFF, 0xE1, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(len(exif)+2))
+		segment = append(segment, exif...)
+		out = append(out[:exifAt], append(segment, out[exifAt:]...)...)
+	}
+	return &photoInfo{Width: cfg.Width, Height: cfg.Height, Orientation: orientation, Data: out}, nil
+}
+
+// jpegScanEnd returns the position of the marker ending the entropy-coded data
+// that starts at pos. Stuffed zero bytes and restart markers are part of the data.
+func jpegScanEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		switch next := data[pos+1]; {
+		case next == 0x00, next == 0xFF, next >= 0xD0 && next <= 0xD7:
+		default:
+			return pos
+		}
+	}
+	return len(data)
+}
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// inspectPNG reads a PNG's dimensions and removes its EXIF, text and timestamp chunks.
+// A non-default orientation is kept in a minimal EXIF chunk.
+func inspectPNG(data []byte) (*photoInfo, error) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	orientation := 1
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	exifAt := -1 // before the first image data chunk
+	for pos := len(pngSignature); pos < len(data); {
+		if pos+12 > len(data) {
+			return nil, errMalformedImage
+		}
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end < pos+12 || end > len(data) {
+			return nil, errMalformedImage
+		}
+		switch typ := string(data[pos+4 : pos+8]); typ {
+		case "eXIf":
+			orientation = exifOrientation(data[pos+8 : end-4])
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			if typ == "IDAT" && exifAt < 0 {
+				exifAt = len(out)
+			}
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	if orientation != 1 && exifAt >= 0 {
+		exif := orientationEXIF(orientation)
+		chunk := binary.BigEndian.AppendUint32(nil, uint32(len(exif)))
+		chunk = append(chunk, "eXIf"...)
+		chunk = append(chunk, exif...)
+		chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+		out = append(out[:exifAt], append(chunk, out[exifAt:]...)...)
+	}
+	return &photoInfo{Width: cfg.Width, Height: cfg.Height, Orientation: orientation, Data: out}, nil
+}
+
+// inspectGIF reads a GIF's dimensions. GIFs carry no EXIF metadata.
+func inspectGIF(data []byte) (*photoInfo, error) {
+	cfg, err := gif.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &photoInfo{Width: cfg.Width, Height: cfg.Height, Orientation: 1, Data: data}, nil
+}
+
+// WebP extended format (VP8X) flags
+const (
+	webpFlagXMP  = 1 << 2
+	webpFlagEXIF = 1 << 3
+)
+
+// inspectWebP reads a WebP's dimensions and removes its EXIF and XMP chunks.
+// A non-default orientation is kept in a minimal EXIF chunk.
+func inspectWebP(data []byte) (*photoInfo, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformedImage
+	}
+
+	info := &photoInfo{Orientation: 1}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:12]...)
+	vp8x := -1 // offset of the VP8X payload in out
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, errMalformedImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size + size%2 // chunks are padded to an even size
+		if end > len(data) {
+			return nil, errMalformedImage
+		}
+		payload := data[pos+8 : pos+8+size]
+		switch string(data[pos : pos+4]) {
+		case "EXIF":
+			info.Orientation = exifOrientation(bytes.TrimPrefix(payload, []byte("Exif\x00\x00")))
+			pos = end
+			continue
+		case "XMP ":
+			pos = end
+			continue
+		case "VP8X":
+			if size < 10 {
+				return nil, errMalformedImage
+			}
+			vp8x = len(out) + 8
+			info.Width = int(uint32(payload[4])|uint32(payload[5])<<8|uint32(payload[6])<<16) + 1
+			info.Height = int(uint32(payload[7])|uint32(payload[8])<<8|uint32(payload[9])<<16) + 1
+		case "VP8 ":
+			if size < 10 || !bytes.Equal(payload[3:6], []byte{0x9D, 0x01, 0x2A}) {
+				return nil, errMalformedImage
+			}
+			if vp8x < 0 {
+				info.Width = int(binary.LittleEndian.Uint16(payload[6:]) & 0x3FFF)
+				info.Height = int(binary.LittleEndian.Uint16(payload[8:]) & 0x3FFF)
+			}
+		case "VP8L":
+			if size < 5 || payload[0] != 0x2F {
+				return nil, errMalformedImage
+			}
+			if vp8x < 0 {
+				bits := binary.LittleEndian.Uint32(payload[1:])
+				info.Width = int(bits&0x3FFF) + 1
+				info.Height = int(bits>>14&0x3FFF) + 1
+			}
+		}
+		out = append(out, data[pos:end]...)
+		pos = end
+	}
+
+	// Only the extended format can carry EXIF, so only it can keep the orientation
+	if vp8x >= 0 {
+		out[vp8x] &^= webpFlagEXIF | webpFlagXMP
+		if info.Orientation != 1 {
+			exif := orientationEXIF(info.Orientation)
+			out = append(out, "EXIF"...)
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(exif)))
+			out = append(out, exif...)
+			if len(exif)%2 == 1 {
+				out = append(out, 0)
+			}
+			out[vp8x] |= webpFlagEXIF
+		}
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	info.Data = out
+	return info, nil
+}
+
+// isoBox is a box of an ISO base media file (HEIC): its type and the position of its payload
+type isoBox struct {
+	Type       string
+	Start, End int
+}
+
+// readBoxes parses the boxes in data[start:end]
+func readBoxes(data []byte, start, end int) ([]isoBox, error) {
+	var boxes []isoBox
+	for pos := start; pos < end; {
+		if pos+8 > end {
+			return nil, errMalformedImage
+		}
+		size := uint64(binary.BigEndian.Uint32(data[pos:]))
+		header := 8
+		switch size {
+		case 0: // extends to the end
+			size = uint64(end - pos)
+		case 1: // 64-bit size
+			if pos+16 > end {
+				return nil, errMalformedImage
+			}
+			size = binary.BigEndian.Uint64(data[pos+8:])
+			header = 16
+		}
+		if size < uint64(header) || size > uint64(end-pos) {
+			return nil, errMalformedImage
+		}
+		boxes = append(boxes, isoBox{Type: string(data[pos+4 : pos+8]), Start: pos + header, End: pos + int(size)})
+		pos += int(size)
+	}
+	return boxes, nil
+}
+
+// findBox returns the first box of type typ
+func findBox(boxes []isoBox, typ string) (isoBox, bool) {
+	for _, b := range boxes {
+		if b.Type == typ {
+			return b, true
+		}
+	}
+	return isoBox{}, false
+}
+
+// heicReader reads big-endian fields of a HEIC box, recording when it runs past the box
+type heicReader struct {
+	data     []byte
+	pos, end int
+	err      error
+}
+
+// uint reads an n-byte unsigned integer (n is 0, 1, 2, 4 or 8)
+func (r *heicReader) uint(n int) uint64 {
+	if r.err != nil || r.pos+n > r.end {
+		r.err = errMalformedImage
+		return 0
+	}
+	var v uint64
+	for _, b := range r.data[r.pos : r.pos+n] {
+		v = v<<8 | uint64(b)
+	}
+	r.pos += n
+	return v
+}
+
+// count reads an n-byte entry count. Entries of fewer than size bytes each
+// could not fit in the rest of the box, so a larger count is malformed, as is
+// any count of entries that take no bytes.
+func (r *heicReader) count(n, size int) uint64 {
+	c := r.uint(n)
+	if r.err == nil && c > 0 && (size == 0 || c > uint64(r.end-r.pos)/uint64(size)) {
+		r.err = errMalformedImage
+		return 0
+	}
+	return c
+}
+
+// cstring reads a null-terminated string
+func (r *heicReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.data[r.pos:r.end], 0)
+	if i < 0 {
+		r.err = errMalformedImage
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+i])
+	r.pos += i + 1
+	return s
+}
+
+// inspectHEIC reads a HEIC's dimensions and rotation from the properties of its
+// primary image and blanks its EXIF and XMP items in place. The rotation is applied
+// by every HEIC decoder, so it does not depend on EXIF.
+func inspectHEIC(data []byte) (*photoInfo, error) {
+	top, err := readBoxes(data, 0, len(data))
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := findBox(top, "meta")
+	if !ok || meta.End-meta.Start < 4 {
+		return nil, errMalformedImage
+	}
+	boxes, err := readBoxes(data, meta.Start+4, meta.End) // after version and flags
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := heicPrimaryItem(data, boxes)
+	if err != nil {
+		return nil, err
+	}
+	info, err := heicImageProperties(data, boxes, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataItems, err := heicMetadataItems(data, boxes)
+	if err != nil {
+		return nil, err
+	}
+	info.Data = data
+	if len(metadataItems) > 0 {
+		info.Data = bytes.Clone(data)
+		if err := blankHEICItems(info.Data, boxes, metadataItems); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// heicPrimaryItem returns the ID of the item holding the main image
+func heicPrimaryItem(data []byte, boxes []isoBox) (uint64, error) {
+	pitm, ok := findBox(boxes, "pitm")
+	if !ok {
+		return 0, errMalformedImage
+	}
+	r := &heicReader{data: data, pos: pitm.Start, end: pitm.End}
+	idSize := 2
+	if r.uint(1) > 0 {
+		idSize = 4
+	}
+	r.uint(3) // flags
+	id := r.uint(idSize)
+	return id, r.err
+}
+
+// heicImageProperties reads the size (ispe) and rotation (irot) properties of an item
+func heicImageProperties(data []byte, boxes []isoBox, item uint64) (*photoInfo, error) {
+	iprp, ok := findBox(boxes, "iprp")
+	if !ok {
+		return nil, errMalformedImage
+	}
+	children, err := readBoxes(data, iprp.Start, iprp.End)
+	if err != nil {
+		return nil, err
+	}
+	ipco, ok := findBox(children, "ipco")
+	ipma, ok2 := findBox(children, "ipma")
+	if !ok || !ok2 {
+		return nil, errMalformedImage
+	}
+	properties, err := readBoxes(data, ipco.Start, ipco.End)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &heicReader{data: data, pos: ipma.Start, end: ipma.End}
+	version := r.uint(1)
+	flags := r.uint(3)
+	info := &photoInfo{Orientation: 1}
+	for n := r.uint(4); n > 0 && r.err == nil; n-- {
+		id := r.uint(2)
+		if version >= 1 {
+			id = id<<16 | r.uint(2)
+		}
+		for m := r.uint(1); m > 0 && r.err == nil; m-- {
+			var index int
+			if flags&1 != 0 {
+				index = int(r.uint(2) & 0x7FFF)
+			} else {
+				index = int(r.uint(1) & 0x7F)
+			}
+			if id != item || index == 0 || index > len(properties) {
+				continue
+			}
+
+			p := properties[index-1] // property indexes start at 1
+			pr := &heicReader{data: data, pos: p.Start, end: p.End}
+			switch p.Type {
+			case "ispe":
+				pr.uint(4) // version and flags
+				info.Width, info.Height = int(pr.uint(4)), int(pr.uint(4))
+			case "irot":
+				// Counter-clockwise quarter turns, as the matching EXIF orientation.
+				// Mirroring (imir) does not change the dimensions, so it is ignored.
+				info.Orientation = [4]int{1, 8, 3, 6}[pr.uint(1)&3]
+			}
+			if pr.err != nil {
+				return nil, pr.err
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return info, nil
+}
+
+// heicMetadataItems returns the IDs of the EXIF and XMP items
+func heicMetadataItems(data []byte, boxes []isoBox) (map[uint64]bool, error) {
+	iinf, ok := findBox(boxes, "iinf")
+	if !ok {
+		return nil, nil
+	}
+	r := &heicReader{data: data, pos: iinf.Start, end: iinf.End}
+	countSize := 2
+	if r.uint(1) > 0 {
+		countSize = 4
+	}
+	r.uint(3) // flags
+	r.uint(countSize)
+	if r.err != nil {
+		return nil, r.err
+	}
+	entries, err := readBoxes(data, r.pos, iinf.End)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[uint64]bool)
+	for _, e := range entries {
+		if e.Type != "infe" {
+			continue
+		}
+		er := &heicReader{data: data, pos: e.Start, end: e.End}
+		version := er.uint(1)
+		er.uint(3) // flags
+		if version < 2 {
+			continue // legacy entries carry no item type
+		}
+		idSize := 2
+		if version >= 3 {
+			idSize = 4
+		}
+		id := er.uint(idSize)
+		er.uint(2) // protection index
+		itemType := string(binary.BigEndian.AppendUint32(nil, uint32(er.uint(4))))
+		er.cstring() // name
+		if er.err != nil {
+			return nil, er.err
+		}
+		switch itemType {
+		case "Exif":
+			items[id] = true
+		case "mime":
+			if er.cstring() == "application/rdf+xml" { // XMP
+				items[id] = true
+			}
+		}
+	}
+	return items, nil
+}
+
+// blankHEICItems zeroes the data of items in place, using their locations (iloc).
+// Readers skip the blanked items as malformed, and no offset in the file changes.
+func blankHEICItems(data []byte, boxes []isoBox, items map[uint64]bool) error {
+	iloc, ok := findBox(boxes, "iloc")
+	if !ok {
+		return errMalformedImage
+	}
+	idat, hasIdat := findBox(boxes, "idat")
+
+	r := &heicReader{data: data, pos: iloc.Start, end: iloc.End}
+	version := r.uint(1)
+	r.uint(3) // flags
+	sizes := r.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xF), int(sizes>>8&0xF)
+	baseOffsetSize, indexSize := int(sizes>>4&0xF), int(sizes&0xF)
+	if version == 0 {
+		indexSize = 0
+	}
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	methodSize := 0
+	if version >= 1 {
+		methodSize = 2
+	}
+	// The smallest item has no extents
+	itemSize := idSize + methodSize + 2 + baseOffsetSize + 2
+	extentSize := indexSize + offsetSize + lengthSize
+	for n := r.count(idSize, itemSize); n > 0 && r.err == nil; n-- {
+		id := r.uint(idSize)
+		method := r.uint(methodSize) & 0xF
+		r.uint(2) // data reference index
+		base := r.uint(baseOffsetSize)
+		for m := r.count(2, extentSize); m > 0 && r.err == nil; m-- {
+			r.uint(indexSize)
+			offset, length := base+r.uint(offsetSize), r.uint(lengthSize)
+			if !items[id] {
+				continue
+			}
+			switch method {
+			case 0: // offset in the file
+			case 1: // offset in the idat box
+				if !hasIdat {
+					return errMalformedImage
+				}
+				offset += uint64(idat.Start)
+			default:
+				return fmt.Errorf("unsupported construction method %d", method)
+			}
+			if length == 0 || offset > uint64(len(data)) || length > uint64(len(data))-offset {
+				return errMalformedImage
+			}
+			clear(data[offset : offset+length])
+		}
+	}
+	return r.err
+}
+
+// exifOrientation reads the orientation tag of TIFF-structured EXIF data.
+// Missing or invalid orientations read as 1 (upright).
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// Orientation is a single SHORT stored in the entry's value field
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientationEXIF returns TIFF-structured EXIF data holding only an orientation tag
+func orientationEXIF(orientation int) []byte {
+	return []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big-endian header, first IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0, // orientation, SHORT, count 1
+		0, 0, 0, 0, // no next IFD
+	}
+}
+
+// orientImage turns src upright according to its EXIF orientation
+func orientImage(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180 degrees
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				dx, dy = y, x
+			case 6: // needs a clockwise quarter turn
+				dx, dy = h-1-y, x
+			case 7: // mirrored along the top-right diagonal
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs a counter-clockwise quarter turn
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
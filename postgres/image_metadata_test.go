@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// gpsSecret is the location stored in the GPS IFD of testEXIF
+const gpsSecret = "SECRET-LOCATION!"
+
+// testEXIF returns little-endian TIFF-structured EXIF data with an orientation
+// and a GPS IFD holding gpsSecret
+func testEXIF(orientation int) []byte {
+	b := []byte{'I', 'I', 42, 0, 8, 0, 0, 0} // header, first IFD at offset 8
+	entry := func(tag, typ uint16, count, value uint32) {
+		b = binary.LittleEndian.AppendUint16(b, tag)
+		b = binary.LittleEndian.AppendUint16(b, typ)
+		b = binary.LittleEndian.AppendUint32(b, count)
+		b = binary.LittleEndian.AppendUint32(b, value)
+	}
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	entry(0x0112, 3, 1, uint32(orientation)) // orientation, SHORT
+	entry(0x8825, 4, 1, 38)                  // GPS IFD pointer, LONG
+	b = append(b, 0, 0, 0, 0)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	entry(0x001C, 7, uint32(len(gpsSecret)), 56) // GPS area information, UNDEFINED
+	b = append(b, 0, 0, 0, 0)
+	return append(b, gpsSecret...)
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithSegments inserts segments after the SOI marker of a JPEG
+func jpegWithSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	s := []byte{0xFF, marker}
+	s = binary.BigEndian.AppendUint16(s, uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+// beforeEOI inserts b after the scan of a JPEG, past where decoding its
+// configuration stops
+func beforeEOI(data, b []byte) []byte {
+	end := len(data) - 2
+	return append(append(bytes.Clone(data[:end]), b...), data[end:]...)
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngWithChunks inserts chunks after the IHDR chunk of a PNG
+func pngWithChunks(data []byte, chunks ...[]byte) []byte {
+	ihdrEnd := len(pngSignature) + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+func pngChunk(typ string, payload []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	c = append(c, typ...)
+	c = append(c, payload...)
+	return binary.BigEndian.AppendUint32(c, 0) // the CRC is not checked
+}
+
+// testWebP returns an extended WebP of the given size holding chunks
+func testWebP(w, h int, chunks ...[]byte) []byte {
+	vp8x := []byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0}
+	vp8x = append(vp8x, byte(w-1), byte((w-1)>>8), byte((w-1)>>16))
+	vp8x = append(vp8x, byte(h-1), byte((h-1)>>8), byte((h-1)>>16))
+	body := append([]byte("WEBP"), webpChunk("VP8X", vp8x)...)
+	body = append(body, webpChunk("VP8L", []byte{0x2F, 0, 0, 0, 0})...)
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(data, body...)
+}
+
+func webpChunk(typ string, payload []byte) []byte {
+	c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	c = append(c, payload...)
+	if len(payload)%2 == 1 {
+		c = append(c, 0)
+	}
+	return c
+}
+
+func isoBoxBytes(typ string, payload ...[]byte) []byte {
+	p := bytes.Join(payload, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(p)))
+	return append(append(b, typ...), p...)
+}
+
+// testHEIC returns a HEIC whose primary item 1 is 4032x3024 and rotated by 270
+// degrees, and whose EXIF item 2 is located by iloc. The mdat box comes first,
+// so the EXIF data is at heicEXIFOffset whatever iloc holds.
+func testHEIC(iloc []byte) []byte {
+	ftyp := isoBoxBytes("ftyp", []byte("mif1\x00\x00\x00\x00mif1heic"))
+	mdat := isoBoxBytes("mdat", testEXIF(1))
+	pitm := isoBoxBytes("pitm", []byte{0, 0, 0, 0, 0, 1})
+	iinf := isoBoxBytes("iinf", []byte{0, 0, 0, 0, 0, 2},
+		isoBoxBytes("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("hvc1\x00")),
+		isoBoxBytes("infe", []byte{2, 0, 0, 0, 0, 2, 0, 0}, []byte("Exif\x00")))
+	ispe := isoBoxBytes("ispe", []byte{0, 0, 0, 0},
+		binary.BigEndian.AppendUint32(nil, 4032), binary.BigEndian.AppendUint32(nil, 3024))
+	iprp := isoBoxBytes("iprp",
+		isoBoxBytes("ipco", ispe, isoBoxBytes("irot", []byte{3})),
+		isoBoxBytes("ipma", []byte{0, 0, 0, 0, 0, 0, 0, 1, 0, 1, 2, 0x81, 0x82}))
+	meta := isoBoxBytes("meta", []byte{0, 0, 0, 0}, pitm, isoBoxBytes("iloc", iloc), iinf, iprp)
+	return bytes.Join([][]byte{ftyp, mdat, meta}, nil)
+}
+
+// heicEXIFOffset is the position of the EXIF data in the mdat box of testHEIC
+const heicEXIFOffset = 24 + 8
+
+// heicILOC returns a version 0 iloc payload with 4-byte offsets and lengths,
+// locating item 2 at the given extents (offset and length pairs)
+func heicILOC(extents ...uint32) []byte {
+	p := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 2, 0, 0}
+	p = binary.BigEndian.AppendUint16(p, uint16(len(extents)/2))
+	for _, v := range extents {
+		p = binary.BigEndian.AppendUint32(p, v)
+	}
+	return p
+}
+
+func TestInspectPhotoRemovesMetadata(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), testEXIF(6)...)
+	plainJPEG := testJPEG(t, 40, 20)
+
+	tests := []struct {
+		name        string
+		data        []byte
+		mimeType    string
+		width       int
+		height      int
+		orientation int
+		removed     []string
+	}{
+		{
+			name:        "jpeg exif and comment",
+			data:        jpegWithSegments(plainJPEG, jpegSegment(0xE1, exif), jpegSegment(0xFE, []byte("Taken at home"))),
+			mimeType:    "image/jpeg",
+			width:       20,
+			height:      40,
+			orientation: 6,
+			removed:     []string{gpsSecret, "Taken at home"},
+		},
+		{
+			name:        "jpeg xmp",
+			data:        jpegWithSegments(plainJPEG, jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+gpsSecret))),
+			mimeType:    "image/jpeg",
+			width:       40,
+			height:      20,
+			orientation: 1,
+			removed:     []string{gpsSecret, "xmpmeta"},
+		},
+		{
+			name:        "png exif and text",
+			data:        pngWithChunks(testPNG(t, 30, 10), pngChunk("tEXt", []byte("Software\x00Phone")), pngChunk("eXIf", testEXIF(8))),
+			mimeType:    "image/png",
+			width:       10,
+			height:      30,
+			orientation: 8,
+			removed:     []string{gpsSecret, "Phone"},
+		},
+		{
+			name:        "webp exif",
+			data:        testWebP(100, 50, webpChunk("EXIF", testEXIF(3))),
+			mimeType:    "image/webp",
+			width:       100,
+			height:      50,
+			orientation: 3,
+			removed:     []string{gpsSecret},
+		},
+		{
+			name:        "heic exif item",
+			data:        testHEIC(heicILOC(heicEXIFOffset, uint32(len(testEXIF(1))))),
+			mimeType:    "image/heic",
+			width:       3024,
+			height:      4032,
+			orientation: 6,
+			removed:     []string{gpsSecret},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := bytes.Clone(tt.data)
+			info, err := inspectPhoto(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.MimeType != tt.mimeType {
+				t.Errorf("mime type %s, want %s", info.MimeType, tt.mimeType)
+			}
+			if info.Width != tt.width || info.Height != tt.height {
+				t.Errorf("dimensions %dx%d, want %dx%d", info.Width, info.Height, tt.width, tt.height)
+			}
+			if info.Orientation != tt.orientation {
+				t.Errorf("orientation %d, want %d", info.Orientation, tt.orientation)
+			}
+			for _, s := range tt.removed {
+				if bytes.Contains(info.Data, []byte(s)) {
+					t.Errorf("%q kept", s)
+				}
+			}
+			if !bytes.Equal(tt.data, input) {
+				t.Error("input modified")
+			}
+
+			// The stripped photo must still inspect the same way
+			again, err := inspectPhoto(info.Data)
+			if err != nil {
+				t.Fatalf("stripped photo: %v", err)
+			}
+			if again.Width != tt.width || again.Height != tt.height || again.Orientation != tt.orientation {
+				t.Errorf("stripped photo is %dx%d with orientation %d", again.Width, again.Height, again.Orientation)
+			}
+		})
+	}
+}
+
+func TestInspectJPEGKeepsImageData(t *testing.T) {
+	plain := testJPEG(t, 40, 20)
+	info, err := inspectPhoto(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(info.Data, plain) {
+		t.Fatal("photo without metadata changed")
+	}
+
+	data := jpegWithSegments(plain, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(1)...)))
+	info, err = inspectPhoto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(info.Data, plain) {
+		t.Fatal("upright photo kept an EXIF segment")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(info.Data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInspectWebPRewritesHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		orientation int
+		wantEXIF    bool
+	}{
+		{"upright", 1, false},
+		{"rotated", 6, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testWebP(100, 50, webpChunk("XMP ", []byte("<x:xmpmeta/>")), webpChunk("EXIF", testEXIF(tt.orientation)))
+			info, err := inspectWebP(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := int(binary.LittleEndian.Uint32(info.Data[4:])); size != len(info.Data)-8 {
+				t.Errorf("RIFF size %d, want %d", size, len(info.Data)-8)
+			}
+			flags := info.Data[20] // first byte of the VP8X payload
+			if flags&webpFlagXMP != 0 {
+				t.Error("XMP flag kept")
+			}
+			if got := flags&webpFlagEXIF != 0; got != tt.wantEXIF {
+				t.Errorf("EXIF flag %v, want %v", got, tt.wantEXIF)
+			}
+			if got := bytes.Contains(info.Data, []byte("EXIF")); got != tt.wantEXIF {
+				t.Errorf("EXIF chunk %v, want %v", got, tt.wantEXIF)
+			}
+		})
+	}
+}
+
+func TestInspectHEICBlanksItems(t *testing.T) {
+	exifLen := uint32(len(testEXIF(1)))
+	// The EXIF item split into two extents
+	data := testHEIC(heicILOC(heicEXIFOffset, 10, heicEXIFOffset+10, exifLen-10))
+	info, err := inspectHEIC(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Data) != len(data) {
+		t.Fatalf("size changed from %d to %d", len(data), len(info.Data))
+	}
+	blanked := info.Data[heicEXIFOffset : heicEXIFOffset+exifLen]
+	if !bytes.Equal(blanked, make([]byte, exifLen)) {
+		t.Fatalf("EXIF item not blanked: %q", blanked)
+	}
+	// Everything but the item is unchanged
+	if !bytes.Equal(info.Data[:heicEXIFOffset], data[:heicEXIFOffset]) ||
+		!bytes.Equal(info.Data[heicEXIFOffset+exifLen:], data[heicEXIFOffset+exifLen:]) {
+		t.Fatal("data outside the EXIF item changed")
+	}
+}
+
+func TestInspectMalformedImages(t *testing.T) {
+	plainPNG := testPNG(t, 4, 4)
+	ihdrEnd := len(pngSignature) + 12 + 13
+
+	tests := []struct {
+		name    string
+		inspect func([]byte) (*photoInfo, error)
+		data    []byte
+	}{
+		{"jpeg short segment", inspectJPEG, beforeEOI(testJPEG(t, 8, 8), []byte{0xFF, 0xE1, 0, 1})},
+		{"jpeg segment past the end", inspectJPEG, beforeEOI(testJPEG(t, 8, 8), []byte{0xFF, 0xE1, 0xFF, 0xFF})},
+		{"png truncated chunk", inspectPNG, append(bytes.Clone(plainPNG[:ihdrEnd]), 0, 0, 1, 0, 't', 'E', 'X', 't')},
+		{"webp not riff", inspectWebP, []byte("RIFX\x00\x00\x00\x00WEBP")},
+		{"webp chunk past the end", inspectWebP, append(testWebP(10, 10), "EXIF\xff\x00\x00\x00"...)},
+		{"webp short vp8x", inspectWebP, append([]byte("RIFF\x0e\x00\x00\x00WEBP"), webpChunk("VP8X", []byte{0, 0})...)},
+		{"heic extent past the end", inspectHEIC, testHEIC(heicILOC(heicEXIFOffset, 1<<30))},
+		{"heic empty extent", inspectHEIC, testHEIC(heicILOC(heicEXIFOffset, 0))},
+		{
+			// Items without extents, claiming more than the box holds
+			"heic item count past the box", inspectHEIC,
+			testHEIC([]byte{0, 0, 0, 0, 0x00, 0x00, 0xFF, 0xFF, 0, 2, 0, 0, 0, 0}),
+		},
+		{
+			// Extents of no bytes, which would otherwise be read 65535 times
+			"heic extents of no bytes", inspectHEIC,
+			testHEIC([]byte{0, 0, 0, 0, 0x00, 0x00, 0, 1, 0, 2, 0, 0, 0xFF, 0xFF}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.inspect(tt.data); !errors.Is(err, errMalformedImage) {
+				t.Fatalf("got %v, want errMalformedImage", err)
+			}
+		})
+	}
+}
+
+func TestInspectPhotoRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"empty", nil, "image/jpeg"},
+		{"html", []byte("<html><body>hello</body></html>"), "image/jpeg"},
+		{"malformed heic", testHEIC(heicILOC(heicEXIFOffset, 1<<30)), "image/heic"},
+		{"declared type differs", testPNG(t, 4, 4), "image/jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verr *ValidationError
+			if _, err := inspectUpload(tt.data, tt.mimeType); !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a validation error", err)
+			}
+		})
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourusername/yourproject/db"
@@ -46,24 +51,207 @@ func main() {
 
 	log.Println("Successfully connected to database")
 
+	// Configure blob storage
+	blobs, err := newBlobStore(ctx)
+	if err != nil {
+		log.Fatalf("Unable to configure blob storage: %v", err)
+	}
+
 	// Initialize layers
 	queries := db.New(pool)
-	photoService := service.NewPhotoService(queries)
+	photoService := service.NewPhotoService(pool, queries, blobs)
+	eventHub := service.NewEventHub()
+	eventHandler := handler.NewEventHandler(eventHub)
 	photoHandler := handler.NewPhotoHandler(photoService)
+	friendshipService := service.NewFriendshipService(pool, queries)
+	friendshipHandler := handler.NewFriendshipHandler(friendshipService)
+	deviceService := service.NewDeviceService(pool, queries)
+	deviceHandler := handler.NewDeviceHandler(deviceService)
+	notificationService := service.NewNotificationService(pool, queries)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+
+	// Start background jobs; they stop when the server exits
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	reaperInterval, err := getDuration("PHOTO_REAPER_INTERVAL", time.Minute)
+	if err != nil {
+		log.Fatalf("Invalid PHOTO_REAPER_INTERVAL: %v", err)
+	}
+	go photoService.RunExpiryReaper(jobsCtx, reaperInterval)
+
+	retention := service.DefaultRetentionPolicy
+	if retention.RestoreWindow, err = getSecondsDuration("PHOTO_RESTORE_WINDOW", retention.RestoreWindow); err != nil {
+		log.Fatalf("Invalid PHOTO_RESTORE_WINDOW: %v", err)
+	}
+	if retention.PurgeAfter, err = getSecondsDuration("PHOTO_PURGE_AFTER", retention.PurgeAfter); err != nil {
+		log.Fatalf("Invalid PHOTO_PURGE_AFTER: %v", err)
+	}
+	photoService.SetRetentionPolicy(retention)
+	if err := photoService.SetReactionMode(service.ReactionMode(getEnv("REACTION_MODE", "single"))); err != nil {
+		log.Fatalf("Invalid REACTION_MODE: %v", err)
+	}
+	if allowed := os.Getenv("REACTION_ALLOWLIST"); allowed != "" {
+		if err := photoService.SetAllowedReactions(strings.Split(allowed, ",")); err != nil {
+			log.Fatalf("Invalid REACTION_ALLOWLIST: %v", err)
+		}
+	}
+	// Hand out short-lived media URLs: presigned by S3 with S3_PRESIGN=true,
+	// otherwise signed with MEDIA_URL_SECRET and served by /media
+	mediaTTL, err := getDuration("MEDIA_URL_TTL", service.DefaultMediaURLTTL)
+	if err != nil {
+		log.Fatalf("Invalid MEDIA_URL_TTL: %v", err)
+	}
+	mediaSecret, err := newMediaSecret()
+	if err != nil {
+		log.Fatalf("Unable to configure media URLs: %v", err)
+	}
+	mediaSigner := service.NewHMACURLSigner(mediaSecret, getEnv("MEDIA_BASE_URL", "http://localhost:8080/media"))
+	uploadTTL, err := getSecondsDuration("UPLOAD_TTL", service.DefaultUploadTTL)
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_TTL: %v", err)
+	}
+	if s3, ok := blobs.(*service.S3BlobStore); ok && os.Getenv("S3_PRESIGN") == "true" {
+		photoService.SetURLSigner(s3, mediaTTL)
+		photoService.SetUploadSigner(s3, uploadTTL)
+	} else {
+		photoService.SetURLSigner(mediaSigner, mediaTTL)
+		photoService.SetUploadSigner(mediaSigner, uploadTTL)
+	}
+	mediaHandler := handler.NewMediaHandler(blobs, mediaSigner, photoService, "/media/")
+	purgeInterval, err := getDuration("PHOTO_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		log.Fatalf("Invalid PHOTO_PURGE_INTERVAL: %v", err)
+	}
+	go photoService.RunPurgeJob(jobsCtx, purgeInterval)
+	uploadCollectorInterval, err := getDuration("UPLOAD_COLLECTOR_INTERVAL", 10*time.Minute)
+	if err != nil {
+		log.Fatalf("Invalid UPLOAD_COLLECTOR_INTERVAL: %v", err)
+	}
+	go photoService.RunUploadCollector(jobsCtx, uploadCollectorInterval)
+
+	// Resumable (tus) uploads keep their partial files in TUS_DIR
+	resumableUploads, err := service.NewResumableUploadService(photoService, queries, getEnv("TUS_DIR", "./data/tus"))
+	if err != nil {
+		log.Fatalf("Unable to configure resumable uploads: %v", err)
+	}
+	tusTTL, err := getSecondsDuration("TUS_UPLOAD_TTL", service.DefaultResumableUploadTTL)
+	if err != nil {
+		log.Fatalf("Invalid TUS_UPLOAD_TTL: %v", err)
+	}
+	resumableUploads.SetTTL(tusTTL)
+	go resumableUploads.RunCollector(jobsCtx, uploadCollectorInterval)
+	tusHandler := handler.NewTusHandler(resumableUploads, "/api/v1/uploads/tus/")
+
+	// Deliver events to the clients of every instance through Postgres NOTIFY,
+	// or only within this process with EVENT_FANOUT=local
+	switch fanout := getEnv("EVENT_FANOUT", "postgres"); fanout {
+	case "postgres":
+		photoService.SetEventPublisher(service.NewPGEventPublisher(queries))
+		go service.NewEventListener(pool, queries, eventHub).Run(jobsCtx)
+	case "local":
+		photoService.SetEventPublisher(eventHub)
+	default:
+		log.Fatalf("Unknown EVENT_FANOUT %q", fanout)
+	}
+
+	// Deliver outbox events to the configured sinks
+	relay := service.NewOutboxRelay(queries)
+	if url := os.Getenv("OUTBOX_WEBHOOK_URL"); url != "" {
+		relay.AddSink(service.NewWebhookSink(url, []byte(os.Getenv("OUTBOX_WEBHOOK_SECRET"))))
+	}
+	if os.Getenv("OUTBOX_LOG") == "true" {
+		relay.AddSink(service.LogSink{})
+	}
+	pushSink, err := newPushSink(queries)
+	if err != nil {
+		log.Fatalf("Unable to configure push notifications: %v", err)
+	}
+	if pushSink != nil {
+		relay.AddSink(pushSink)
+	}
+	relayInterval, err := getDuration("OUTBOX_RELAY_INTERVAL", time.Second)
+	if err != nil {
+		log.Fatalf("Invalid OUTBOX_RELAY_INTERVAL: %v", err)
+	}
+	go relay.Run(jobsCtx, relayInterval)
+
+	// Configure authentication
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		log.Fatalf("Unable to configure authentication: %v", err)
+	}
 
 	// Setup router
 	r := mux.NewRouter()
 
+	// Event streams also accept the token as a query parameter
+	r.Handle("/api/v1/ws", authenticator.QueryTokenMiddleware(http.HandlerFunc(eventHandler.ServeWebSocket))).Methods("GET")
+	r.Handle("/api/v1/me/events", authenticator.QueryTokenMiddleware(http.HandlerFunc(eventHandler.StreamEvents))).Methods("GET")
+
+	// tus clients discover the server's capabilities without authenticating
+	r.HandleFunc("/api/v1/uploads/tus", tusHandler.Options).Methods("OPTIONS")
+
 	// API routes
 	api := r.PathPrefix("/api/v1").Subrouter()
+	api.Use(authenticator.Middleware)
 	
 	// Photo endpoints
+	api.HandleFunc("/photos", photoHandler.UploadPhoto).Methods("POST")
+	api.HandleFunc("/photos/uploads", photoHandler.CreateUpload).Methods("POST")
+	api.HandleFunc("/photos/uploads/{id}/complete", photoHandler.CompleteUpload).Methods("POST")
+	api.HandleFunc("/uploads/tus", tusHandler.CreateUpload).Methods("POST")
+	api.HandleFunc("/uploads/tus/{id}", tusHandler.GetOffset).Methods("HEAD")
+	api.HandleFunc("/uploads/tus/{id}", tusHandler.AppendChunk).Methods("PATCH")
+	api.HandleFunc("/uploads/tus/{id}", tusHandler.TerminateUpload).Methods("DELETE")
 	api.HandleFunc("/photos/{id}", photoHandler.GetPhotoByID).Methods("GET")
+	api.HandleFunc("/photos/{id}", photoHandler.DeletePhoto).Methods("DELETE")
+	api.HandleFunc("/photos/{id}/restore", photoHandler.RestorePhoto).Methods("POST")
 	api.HandleFunc("/users/{user_id}/photos", photoHandler.GetUserPhotos).Methods("GET")
+	api.HandleFunc("/users/{user_id}/photos/simple", photoHandler.GetUserPhotosSimple).Methods("GET")
+	api.HandleFunc("/photos/{id}/view", photoHandler.MarkPhotoViewed).Methods("POST")
+	api.HandleFunc("/photos/{id}/hide", photoHandler.HidePhoto).Methods("POST")
+	api.HandleFunc("/me/inbox", photoHandler.GetInbox).Methods("GET")
+	api.HandleFunc("/me/feed", photoHandler.GetFeed).Methods("GET")
+
+	// Home-screen widget endpoints
+	api.HandleFunc("/me/widget", photoHandler.GetWidget).Methods("GET")
+	api.HandleFunc("/me/widget/settings", photoHandler.GetWidgetSettings).Methods("GET")
+	api.HandleFunc("/me/widget/settings", photoHandler.UpdateWidgetSettings).Methods("PUT")
 	
 	// Reaction endpoints
 	api.HandleFunc("/photos/{id}/reactions", photoHandler.AddReaction).Methods("POST")
 	api.HandleFunc("/photos/{id}/reactions", photoHandler.RemoveReaction).Methods("DELETE")
+	api.HandleFunc("/photos/{id}/reactions/summary", photoHandler.GetReactionSummary).Methods("GET")
+	api.HandleFunc("/reactions/allowed", photoHandler.GetAllowedReactions).Methods("GET")
+
+	// Reply endpoints
+	api.HandleFunc("/photos/{id}/replies", photoHandler.CreateReply).Methods("POST")
+	api.HandleFunc("/photos/{id}/replies", photoHandler.ListReplies).Methods("GET")
+	api.HandleFunc("/photos/{id}/replies/{reply_id}", photoHandler.UpdateReply).Methods("PATCH")
+	api.HandleFunc("/photos/{id}/replies/{reply_id}", photoHandler.DeleteReply).Methods("DELETE")
+
+	// Stored photos are served through signed, expiring URLs
+	r.PathPrefix("/media/").HandlerFunc(mediaHandler.ServeMedia).Methods("GET", "HEAD")
+	r.PathPrefix("/media/").HandlerFunc(mediaHandler.UploadMedia).Methods("PUT")
+
+	// Friendship endpoints
+	api.HandleFunc("/me/friends", friendshipHandler.ListFriends).Methods("GET")
+	api.HandleFunc("/me/friends/requests", friendshipHandler.ListFriendRequests).Methods("GET")
+	api.HandleFunc("/me/friends/requests", friendshipHandler.SendFriendRequest).Methods("POST")
+	api.HandleFunc("/me/friends/requests/{user_id}/accept", friendshipHandler.AcceptFriendRequest).Methods("POST")
+	api.HandleFunc("/me/friends/requests/{user_id}/decline", friendshipHandler.DeclineFriendRequest).Methods("POST")
+	api.HandleFunc("/me/blocks", friendshipHandler.BlockUser).Methods("POST")
+
+	// Device and notification settings endpoints
+	api.HandleFunc("/me/devices", deviceHandler.RegisterDevice).Methods("POST")
+	api.HandleFunc("/me/devices", deviceHandler.UnregisterDevice).Methods("DELETE")
+	api.HandleFunc("/me/notification-settings", deviceHandler.GetNotificationSettings).Methods("GET")
+	api.HandleFunc("/me/notification-settings", deviceHandler.UpdateNotificationSettings).Methods("PUT")
+
+	// Notification inbox endpoints
+	api.HandleFunc("/me/notifications", notificationHandler.ListNotifications).Methods("GET")
+	api.HandleFunc("/me/notifications/read-all", notificationHandler.MarkAllNotificationsRead).Methods("POST")
+	api.HandleFunc("/me/notifications/{id}/read", notificationHandler.MarkNotificationRead).Methods("POST")
 
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -82,3 +270,169 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// newBlobStore builds the blob store selected by BLOB_BACKEND ("local" or "s3")
+func newBlobStore(ctx context.Context) (service.BlobStore, error) {
+	switch backend := getEnv("BLOB_BACKEND", "local"); backend {
+	case "local":
+		return service.NewLocalBlobStore(
+			getEnv("BLOB_DIR", "./data/blobs"),
+			getEnv("MEDIA_BASE_URL", "http://localhost:8080/media"),
+		)
+	case "s3":
+		region := getEnv("S3_REGION", "us-east-1")
+		store, err := service.NewS3BlobStore(service.S3Config{
+			Endpoint:        getEnv("S3_ENDPOINT", "localhost:9000"),
+			Region:          region,
+			Bucket:          getEnv("S3_BUCKET", "photos"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			UseSSL:          os.Getenv("S3_USE_SSL") == "true",
+			PublicBaseURL:   os.Getenv("S3_PUBLIC_BASE_URL"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := store.EnsureBucket(ctx, region); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown BLOB_BACKEND %q", backend)
+	}
+}
+
+// newMediaSecret returns the key media URLs are signed with, MEDIA_URL_SECRET.
+// Without it a random key is used, so URLs stop working when the server restarts
+// and are not accepted by other instances.
+func newMediaSecret() ([]byte, error) {
+	if secret := os.Getenv("MEDIA_URL_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate media URL secret: %w", err)
+	}
+	log.Println("WARNING: MEDIA_URL_SECRET is not set; media URLs are signed with a random key")
+	return secret, nil
+}
+
+// newPushSink builds the push notification sink. iOS devices are reached through
+// APNs (APNS_KEY_FILE, APNS_KEY_ID, APNS_TEAM_ID, APNS_TOPIC, APNS_SANDBOX),
+// Android and web devices through FCM (FCM_CREDENTIALS_FILE). PUSH_FAKE=true
+// records notifications instead of sending them. It returns nil when no
+// provider is configured.
+func newPushSink(queries *db.Queries) (*service.PushSink, error) {
+	sink := service.NewPushSink(queries)
+
+	if os.Getenv("PUSH_FAKE") == "true" {
+		fake := &service.FakeNotifier{}
+		for _, platform := range []string{service.PlatformIOS, service.PlatformAndroid, service.PlatformWeb} {
+			sink.SetNotifier(platform, fake)
+		}
+		log.Println("Push notifications are recorded, not sent (PUSH_FAKE=true)")
+		return sink, nil
+	}
+
+	configured := false
+	if path := os.Getenv("APNS_KEY_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read APNs key: %w", err)
+		}
+		key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse APNs key: %w", err)
+		}
+		sink.SetNotifier(service.PlatformIOS, service.NewAPNsNotifier(service.APNsConfig{
+			KeyID:      os.Getenv("APNS_KEY_ID"),
+			TeamID:     os.Getenv("APNS_TEAM_ID"),
+			PrivateKey: key,
+			Topic:      os.Getenv("APNS_TOPIC"),
+			Sandbox:    os.Getenv("APNS_SANDBOX") == "true",
+		}))
+		configured = true
+	}
+	if path := os.Getenv("FCM_CREDENTIALS_FILE"); path != "" {
+		credentials, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read FCM credentials: %w", err)
+		}
+		fcm, err := service.NewFCMNotifier(credentials)
+		if err != nil {
+			return nil, err
+		}
+		sink.SetNotifier(service.PlatformAndroid, fcm)
+		sink.SetNotifier(service.PlatformWeb, fcm)
+		configured = true
+	}
+
+	if !configured {
+		return nil, nil
+	}
+	return sink, nil
+}
+
+// newAuthenticator builds the JWT authenticator from JWT_HS256_SECRET and/or
+// JWT_RS256_PUBLIC_KEY_FILE. AUTH_DEV_BYPASS=true additionally accepts the
+// X-Dev-User-ID header for local testing.
+func newAuthenticator() (*handler.Authenticator, error) {
+	cfg := handler.AuthConfig{
+		HMACSecret: []byte(os.Getenv("JWT_HS256_SECRET")),
+		Issuer:     os.Getenv("JWT_ISSUER"),
+		Audience:   os.Getenv("JWT_AUDIENCE"),
+		DevBypass:  os.Getenv("AUTH_DEV_BYPASS") == "true",
+	}
+	if path := os.Getenv("JWT_RS256_PUBLIC_KEY_FILE"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RS256 public key: %w", err)
+		}
+		cfg.RSAPublicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RS256 public key: %w", err)
+		}
+	}
+	if cfg.DevBypass {
+		log.Println("WARNING: AUTH_DEV_BYPASS is enabled; requests can authenticate with " + handler.DevUserIDHeader)
+	}
+	return handler.NewAuthenticator(cfg)
+}
+
+// getEnv returns the environment variable or a fallback when it is unset
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getDuration parses a duration environment variable such as "30s" or "5m",
+// returning the fallback when it is unset
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return d, nil
+}
+
+// getSecondsDuration is getDuration for settings the database receives as int4
+// seconds; longer durations are rejected rather than overflowing
+func getSecondsDuration(key string, fallback time.Duration) (time.Duration, error) {
+	d, err := getDuration(key, fallback)
+	if err != nil {
+		return 0, err
+	}
+	if limit := time.Duration(math.MaxInt32) * time.Second; d > limit {
+		return 0, fmt.Errorf("%s must be at most %s", key, limit)
+	}
+	return d, nil
+}
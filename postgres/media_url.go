@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultMediaURLTTL is how long signed media URLs stay valid by default
+const DefaultMediaURLTTL = 15 * time.Minute
+
+// URLSigner issues short-lived URLs granting read access to a stored object
+type URLSigner interface {
+	// SignURL returns a URL for the object stored under key that expires after at most ttl
+	SignURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// HMACURLSigner signs URLs served by the media handler:
+// baseURL/key?expires=<unix seconds>&sig=<HMAC-SHA256 of method, key and expiry>
+type HMACURLSigner struct {
+	secret  []byte
+	baseURL string
+	now     func() time.Time
+}
+
+// NewHMACURLSigner creates a signer for URLs below baseURL.
+// Every instance serving the URLs must share the secret.
+func NewHMACURLSigner(secret []byte, baseURL string) *HMACURLSigner {
+	return &HMACURLSigner{
+		secret:  secret,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		now:     time.Now,
+	}
+}
+
+// SignURL returns a signed URL for key. Expiry times are rounded to half the ttl,
+// so repeated requests get the same URL (and clients can cache the object) for a
+// while; every URL stays valid for between ttl/2 and ttl.
+func (s *HMACURLSigner) SignURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	step := int64(ttl / 2 / time.Second)
+	if step < 1 {
+		step = 1
+	}
+	now := s.now().Unix()
+	return s.sign(http.MethodGet, key, now-now%step+int64(ttl/time.Second)), nil
+}
+
+// SignUploadURL returns a URL the object can be uploaded to with a PUT request
+// until ttl has passed
+func (s *HMACURLSigner) SignUploadURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return s.sign(http.MethodPut, key, s.now().Add(ttl).Unix()), nil
+}
+
+// sign builds the URL of key granting method until expires
+func (s *HMACURLSigner) sign(method, key string, expires int64) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", s.signature(method, key, expires))
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode()
+}
+
+// Verify checks the expires and sig query parameters of a signed URL for
+// method (GET or PUT) and key
+func (s *HMACURLSigner) Verify(method, key, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: missing or malformed signature", ErrForbidden)
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signature(method, key, exp))) {
+		return fmt.Errorf("%w: invalid signature", ErrForbidden)
+	}
+	if s.now().Unix() > exp {
+		return fmt.Errorf("%w: signed URL has expired", ErrForbidden)
+	}
+	return nil
+}
+
+// signature authenticates the method, key and expiry time of a URL
+func (s *HMACURLSigner) signature(method, key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(method))
+	mac.Write([]byte{0})
+	mac.Write([]byte(key))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetURLSigner makes responses carry short-lived signed URLs for photos and
+// thumbnails instead of their stored permanent URLs. A nil signer returns the
+// stored URLs.
+func (s *PhotoService) SetURLSigner(signer URLSigner, ttl time.Duration) {
+	s.urls = signer
+	s.urlTTL = ttl
+}
+
+// signedURLEpoch numbers the periods of half the URL ttl, 0 without a signer.
+// A URL signed during one period stays valid until the end of the next, so a
+// response with signed URLs can be reused while the epoch is unchanged.
+func (s *PhotoService) signedURLEpoch() int64 {
+	if s.urls == nil {
+		return 0
+	}
+	step := int64(s.urlTTL / 2 / time.Second)
+	if step < 1 {
+		step = 1
+	}
+	return time.Now().Unix() / step
+}
+
+// signPhotoURLs replaces the URLs of photos with signed ones derived from their keys
+func (s *PhotoService) signPhotoURLs(ctx context.Context, photos []PhotoResponse) error {
+	for i := range photos {
+		if err := s.signPhotoURL(ctx, &photos[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signPhotoURL replaces the photo and thumbnail URLs of p with signed ones.
+// Photos without a key keep their stored URLs.
+func (s *PhotoService) signPhotoURL(ctx context.Context, p *PhotoResponse) error {
+	if s.urls == nil || p.Key == nil || *p.Key == "" {
+		return nil
+	}
+	photoURL, err := s.urls.SignURL(ctx, *p.Key, s.urlTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign photo URL: %w", err)
+	}
+	p.PhotoURL = photoURL
+	if p.ThumbnailURL != nil {
+		thumbnailURL, err := s.urls.SignURL(ctx, ThumbnailKey(*p.Key, PrimaryThumbnailVariant), s.urlTTL)
+		if err != nil {
+			return fmt.Errorf("failed to sign thumbnail URL: %w", err)
+		}
+		p.ThumbnailURL = &thumbnailURL
+	}
+	return nil
+}
+
+// MediaContentType returns the Content-Type the object stored under key is served
+// with: the verified mime type of the photo stored there, or JPEG for thumbnails,
+// which are always generated as JPEG. Thumbnails are served as long as their photo
+// is. Objects of deleted photos and objects that belong to no photo, such as
+// staged direct uploads, are ErrObjectNotFound; those of expired photos are ErrExpired.
+func (s *PhotoService) MediaContentType(ctx context.Context, key string) (string, error) {
+	keys := s.thumbnailer.PhotoKeys(key)
+	thumbnail := keys != nil
+	if !thumbnail {
+		keys = []string{key}
+	}
+	mimeType, err := s.queries.GetPhotoMimeTypeByKey(ctx, keys)
+	if errors.Is(err, pgx.ErrNoRows) {
+		expired, expErr := s.queries.IsPhotoKeyExpired(ctx, keys)
+		if expErr == nil && expired {
+			return "", fmt.Errorf("%w: photo has expired", ErrExpired)
+		}
+		return "", ErrObjectNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get photo type: %w", err)
+	}
+	if thumbnail {
+		return "image/jpeg", nil
+	}
+	if mimeType == nil || !IsSupportedPhotoType(*mimeType) {
+		return "application/octet-stream", nil
+	}
+	return *mimeType, nil
+}
+
+// objectURL returns the URL clients should use for the object stored under key:
+// a signed URL when a signer is set, its permanent URL otherwise
+func (s *PhotoService) objectURL(ctx context.Context, key string) (string, error) {
+	if s.urls == nil {
+		return s.blobs.URL(key), nil
+	}
+	u, err := s.urls.SignURL(ctx, key, s.urlTTL)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign object URL: %w", err)
+	}
+	return u, nil
+}
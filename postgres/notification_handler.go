@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+func NewNotificationHandler(notificationService *service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// ListNotifications godoc
+// @Summary List notifications
+// @Description Get a page of the authenticated user's notifications (reactions to their photos, photos sent to them), newest first,
+// @Description with the number of unread notifications. Repeat reactions from the same friend appear once.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Success 200 {object} service.NotificationPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/notifications [get]
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.notificationService.ListNotifications(r.Context(), userID, cursor, limit)
+	if err != nil {
+		respondServiceError(w, err, "failed to list notifications")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Description Mark one of the authenticated user's notifications as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Notification ID"
+// @Success 200 {object} service.NotificationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/notifications/{id}/read [post]
+func (h *NotificationHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	notificationID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid notification ID")
+		return
+	}
+
+	notification, err := h.notificationService.MarkRead(r.Context(), notificationID, userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to mark notification read")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read
+// @Description Mark all of the authenticated user's notifications as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} MarkAllReadResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/notifications/read-all [post]
+func (h *NotificationHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := h.notificationService.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to mark notifications read")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, MarkAllReadResponse{Marked: n})
+}
+
+// Response types
+type MarkAllReadResponse struct {
+	Marked int64 `json:"marked"` // notifications that were unread
+}
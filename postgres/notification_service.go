@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// Notification kinds, as stored in notifications.kind
+const (
+	NotificationKindReaction = "reaction"
+	NotificationKindPhoto    = "photo"
+)
+
+// NotificationResponse represents an in-app notification in the API response
+type NotificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`     // reaction or photo
+	ActorID   uuid.UUID  `json:"actor_id"` // who reacted or sent the photo
+	PhotoID   uuid.UUID  `json:"photo_id"`
+	Emoji     *string    `json:"emoji,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// NotificationPage is one page of notifications with the user's unread count
+type NotificationPage struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	NextCursor    *string                `json:"next_cursor"` // null on the last page
+	UnreadCount   int64                  `json:"unread_count"`
+}
+
+// NotificationService manages the in-app notification inbox
+type NotificationService struct {
+	pool    *pgxpool.Pool
+	queries *db.Queries
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(pool *pgxpool.Pool, queries *db.Queries) *NotificationService {
+	return &NotificationService{
+		pool:    pool,
+		queries: queries,
+	}
+}
+
+// recordNotifications updates the inbox for a photo event as part of the transaction of q.
+// The sender is notified of reactions by others, recipients of new photos.
+func recordNotifications(ctx context.Context, q *db.Queries, e Event) error {
+	var err error
+	switch e.Type {
+	case EventReactionAdded:
+		if e.ActorID == e.SenderID || e.Reaction == nil {
+			return nil
+		}
+		err = q.UpsertReactionNotification(ctx, db.UpsertReactionNotificationParams{
+			UserID:  e.SenderID,
+			ActorID: e.ActorID,
+			PhotoID: e.PhotoID,
+			Emoji:   e.Reaction.Emoji,
+		})
+	case EventReactionRemoved:
+		err = q.DeleteReactionNotification(ctx, db.DeleteReactionNotificationParams{
+			PhotoID: e.PhotoID,
+			ActorID: e.ActorID,
+		})
+	case EventPhotoCreated:
+		if len(e.RecipientIDs) == 0 {
+			return nil
+		}
+		err = q.CreatePhotoNotifications(ctx, db.CreatePhotoNotificationsParams{
+			UserIds: e.RecipientIDs,
+			ActorID: e.ActorID,
+			PhotoID: e.PhotoID,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record %s notification: %w", e.Type, err)
+	}
+	return nil
+}
+
+// ListNotifications returns one page of userID's notifications, newest first
+func (s *NotificationService) ListNotifications(ctx context.Context, userID uuid.UUID, cursor string, limit int32) (*NotificationPage, error) {
+	ks, err := parseKeyset(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = clampPageSize(limit)
+	notifications, err := s.queries.ListNotifications(ctx, db.ListNotificationsParams{
+		UserID:          userID,
+		HasCursor:       ks.HasCursor,
+		CursorCreatedAt: ks.CreatedAt,
+		CursorID:        ks.ID,
+		PageSize:        limit + 1, // one extra row tells us whether there is a next page
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	unread, err := s.queries.CountUnreadNotifications(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+
+	var nextCursor *string
+	if len(notifications) > int(limit) {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		c := encodeCursor(last.CreatedAt, last.ID)
+		nextCursor = &c
+	}
+
+	responses := make([]NotificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		responses = append(responses, newNotificationResponse(n))
+	}
+
+	return &NotificationPage{
+		Notifications: responses,
+		NextCursor:    nextCursor,
+		UnreadCount:   unread,
+	}, nil
+}
+
+// MarkRead marks one of userID's notifications as read
+func (s *NotificationService) MarkRead(ctx context.Context, notificationID, userID uuid.UUID) (*NotificationResponse, error) {
+	n, err := s.queries.MarkNotificationRead(ctx, db.MarkNotificationReadParams{ID: notificationID, UserID: userID})
+	if err != nil {
+		return nil, mapDBError(err, "notification", "mark notification read")
+	}
+	response := newNotificationResponse(n)
+	return &response, nil
+}
+
+// MarkAllRead marks all of userID's notifications as read and returns how many were unread
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	n, err := s.queries.MarkAllNotificationsRead(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return n, nil
+}
+
+// newNotificationResponse converts a notifications row to its API response
+func newNotificationResponse(n db.Notification) NotificationResponse {
+	return NotificationResponse{
+		ID:        n.ID,
+		Kind:      n.Kind,
+		ActorID:   n.ActorID,
+		PhotoID:   n.PhotoID,
+		Emoji:     n.Emoji,
+		CreatedAt: n.CreatedAt,
+		ReadAt:    n.ReadAt,
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+const (
+	// outboxBatchSize is the number of events claimed at once
+	outboxBatchSize = 50
+	// outboxClaimLease is how long other relays skip claimed events. A relay stops
+	// starting deliveries after half of it, and the rest of its batch is claimed again later.
+	outboxClaimLease = 10 * time.Minute
+	// outboxMaxAttempts is the number of deliveries tried before an event is marked failed
+	outboxMaxAttempts = 10
+	// outboxBaseBackoff is the delay before the first retry; it doubles with every attempt
+	outboxBaseBackoff = 5 * time.Second
+	// outboxMaxBackoff caps the delay between retries
+	outboxMaxBackoff = time.Hour
+	// outboxRetention is how long delivered events are kept
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// OutboxMessage is a domain event read from the outbox
+type OutboxMessage struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	PhotoID   uuid.UUID       `json:"photo_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempt   int32           `json:"attempt"` // 1 for the first delivery
+}
+
+// Event decodes the payload of the message
+func (m OutboxMessage) Event() (Event, error) {
+	var w wireEvent
+	if err := json.Unmarshal(m.Payload, &w); err != nil {
+		return Event{}, fmt.Errorf("invalid outbox payload: %w", err)
+	}
+	e := w.Event
+	e.ID = m.ID // the payload is encoded before the ID is assigned
+	e.RecipientIDs = w.RecipientIDs
+	return e, nil
+}
+
+// OutboxSink receives outbox events. Delivery is at least once: a message is
+// retried on the sinks that failed, and can still repeat on one that succeeded
+// (e.g. if recording its delivery failed), so sinks should ignore message IDs
+// they have already handled. Name identifies the sink's deliveries and must be
+// unique among the relay's sinks.
+type OutboxSink interface {
+	Name() string
+	Deliver(ctx context.Context, msg OutboxMessage) error
+}
+
+// OutboxRelay delivers outbox events to its sinks, retrying failures with backoff
+type OutboxRelay struct {
+	queries *db.Queries
+	sinks   []OutboxSink
+}
+
+// NewOutboxRelay creates a relay that delivers every event to all sinks
+func NewOutboxRelay(queries *db.Queries, sinks ...OutboxSink) *OutboxRelay {
+	return &OutboxRelay{
+		queries: queries,
+		sinks:   sinks,
+	}
+}
+
+// AddSink registers another sink
+func (r *OutboxRelay) AddSink(sink OutboxSink) {
+	r.sinks = append(r.sinks, sink)
+}
+
+// RelayOnce delivers one batch of due events and returns how many were delivered.
+// The events are claimed in a short statement and delivered outside any transaction,
+// so slow sinks hold no locks. Several relays can run at once: each claims different rows.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	claimedAt := time.Now()
+	rows, err := r.queries.ClaimOutboxEvents(ctx, db.ClaimOutboxEventsParams{
+		LeaseSeconds: int32(outboxClaimLease.Seconds()),
+		BatchSize:    outboxBatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	slices.SortFunc(rows, func(a, b db.ClaimOutboxEventsRow) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	delivered := 0
+	for _, row := range rows {
+		if time.Since(claimedAt) > outboxClaimLease/2 {
+			break
+		}
+		msg := OutboxMessage{
+			ID:        row.ID,
+			Type:      row.EventType,
+			PhotoID:   row.PhotoID,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt,
+			Attempt:   row.Attempts + 1,
+		}
+
+		if err := r.deliver(ctx, msg, row.DeliveredSinks); err != nil {
+			giveUp := msg.Attempt >= outboxMaxAttempts
+			if giveUp {
+				log.Printf("outbox: giving up on event %d after %d attempts: %v", msg.ID, msg.Attempt, err)
+			}
+			lastError := err.Error()
+			err = r.queries.RecordOutboxEventFailure(ctx, db.RecordOutboxEventFailureParams{
+				LastError:         &lastError,
+				RetryAfterSeconds: int32(outboxBackoff(msg.Attempt).Seconds()),
+				GiveUp:            giveUp,
+				ID:                msg.ID,
+			})
+			if err != nil {
+				return delivered, fmt.Errorf("failed to record outbox failure: %w", err)
+			}
+			continue
+		}
+
+		if err := r.queries.MarkOutboxEventDelivered(ctx, msg.ID); err != nil {
+			return delivered, fmt.Errorf("failed to mark outbox event delivered: %w", err)
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// deliver hands a message to every sink not in done, recording each success so
+// that a retry only goes to the sinks that failed
+func (r *OutboxRelay) deliver(ctx context.Context, msg OutboxMessage, done []string) error {
+	var errs []error
+	for _, sink := range r.sinks {
+		if slices.Contains(done, sink.Name()) {
+			continue
+		}
+		if err := sink.Deliver(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		err := r.queries.RecordOutboxDelivery(ctx, db.RecordOutboxDeliveryParams{
+			EventID: msg.ID,
+			Sink:    sink.Name(),
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to record delivery: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outboxBackoff is the delay before retrying after the given failed attempt
+func outboxBackoff(attempt int32) time.Duration {
+	d := outboxBaseBackoff
+	for i := int32(1); i < attempt && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
+
+// Run relays due events every interval until ctx is canceled.
+// It keeps going without waiting while full batches are delivered.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				log.Printf("outbox relay: %v", err)
+				return
+			}
+			if n < outboxBatchSize {
+				break
+			}
+		}
+
+		deleted, err := r.queries.DeleteDeliveredOutboxEvents(ctx, int32(outboxRetention.Seconds()))
+		if err != nil {
+			log.Printf("outbox relay: failed to delete delivered events: %v", err)
+		} else if deleted > 0 {
+			log.Printf("outbox relay: deleted %d delivered events", deleted)
+		}
+	})
+}
+
+// LogSink logs every event. It is useful for local development.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Deliver(_ context.Context, msg OutboxMessage) error {
+	log.Printf("outbox event %d: %s photo=%s %s", msg.ID, msg.Type, msg.PhotoID, msg.Payload)
+	return nil
+}
+
+// WebhookSink POSTs every event as JSON to a URL. When a secret is set, the body is
+// signed with HMAC-SHA256 in the X-Signature-256 header ("sha256=<hex>").
+type WebhookSink struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+}
+
+// NewWebhookSink creates a webhook sink with a 10 second timeout
+func NewWebhookSink(url string, secret []byte) *WebhookSink {
+	return &WebhookSink{
+		URL:    url,
+		Secret: secret,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Deliver(ctx context.Context, msg OutboxMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(msg.ID, 10))
+	req.Header.Set("X-Event-Type", msg.Type)
+	if len(s.Secret) > 0 {
+		mac := hmac.New(sha256.New, s.Secret)
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
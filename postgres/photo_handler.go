@@ -3,6 +3,8 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -21,14 +23,19 @@ func NewPhotoHandler(photoService *service.PhotoService) *PhotoHandler {
 
 // GetPhotoByID godoc
 // @Summary Get a photo with its reactions
-// @Description Get a single photo by ID with all its reactions
+// @Description Get a single photo by ID with all its reactions. Only the sender and their friends can see it.
+// @Description Expired photos return 410.
 // @Tags photos
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Photo ID"
 // @Success 200 {object} service.PhotoResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /photos/{id} [get]
 func (h *PhotoHandler) GetPhotoByID(w http.ResponseWriter, r *http.Request) {
@@ -39,14 +46,15 @@ func (h *PhotoHandler) GetPhotoByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
 	// Use the optimized single-query approach for best performance
-	photo, err := h.photoService.GetPhotoWithReactionsSingleQuery(r.Context(), photoID)
+	photo, err := h.photoService.GetPhotoWithReactionsSingleQuery(r.Context(), viewerID, photoID)
 	if err != nil {
-		if err.Error() == "photo not found" {
-			respondError(w, http.StatusNotFound, "photo not found")
-			return
-		}
-		respondError(w, http.StatusInternalServerError, "failed to get photo")
+		respondServiceError(w, err, "failed to get photo")
 		return
 	}
 
@@ -55,15 +63,20 @@ func (h *PhotoHandler) GetPhotoByID(w http.ResponseWriter, r *http.Request) {
 
 // GetUserPhotos godoc
 // @Summary Get user's photos with reactions
-// @Description Get all photos by a user with their reactions (paginated)
+// @Description Get a page of photos by a user with all their reactions, newest first.
+// @Description Only the user and their friends can list them.
 // @Tags photos
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param user_id path string true "User ID"
 // @Param limit query int false "Limit" default(20)
-// @Param offset query int false "Offset" default(0)
-// @Success 200 {array} service.PhotoResponse
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Param include query string false "summary: return reaction_summary counters instead of the reactions list"
+// @Success 200 {object} service.PhotoPage
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /users/{user_id}/photos [get]
 func (h *PhotoHandler) GetUserPhotos(w http.ResponseWriter, r *http.Request) {
@@ -74,43 +87,39 @@ func (h *PhotoHandler) GetUserPhotos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters
-	limit := int32(20)
-	offset := int32(0)
-	
-	if l := r.URL.Query().Get("limit"); l != "" {
-		var limitInt int
-		if _, err := fmt.Sscanf(l, "%d", &limitInt); err == nil && limitInt > 0 {
-			limit = int32(limitInt)
-		}
-	}
-	
-	if o := r.URL.Query().Get("offset"); o != "" {
-		var offsetInt int
-		if _, err := fmt.Sscanf(o, "%d", &offsetInt); err == nil && offsetInt >= 0 {
-			offset = int32(offsetInt)
-		}
+	viewerID, ok := requireUserID(w, r)
+	if !ok {
+		return
 	}
 
-	photos, err := h.photoService.GetPhotosByUserWithReactions(r.Context(), userID, limit, offset)
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.photoService.GetPhotosByUserWithReactions(r.Context(), viewerID, userID, cursor, limit, parseReactionView(r))
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to get photos")
+		respondServiceError(w, err, "failed to get photos")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, photos)
+	respondJSON(w, http.StatusOK, page)
 }
 
 // AddReaction godoc
 // @Summary Add a reaction to a photo
-// @Description Add or update a user's reaction to a photo
+// @Description Add a reaction of the authenticated user to a photo.
+// @Description The emoji must be exactly one emoji and, when the server restricts reactions, one of GET /reactions/allowed.
+// @Description In single mode it replaces the user's previous reaction; in stack mode users can add several distinct emojis;
+// @Description in burst mode repeating an emoji increases its burst_count.
 // @Tags reactions
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Photo ID"
 // @Param reaction body AddReactionRequest true "Reaction"
 // @Success 201 {object} service.ReactionResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /photos/{id}/reactions [post]
 func (h *PhotoHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
@@ -121,26 +130,20 @@ func (h *PhotoHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req AddReactionRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request body")
+	userID, ok := requireUserID(w, r)
+	if !ok {
 		return
 	}
 
-	userID, err := uuid.Parse(req.UserID)
-	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
-		return
-	}
-
-	if req.Emoji == "" {
-		respondError(w, http.StatusBadRequest, "emoji is required")
+	var req AddReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	reaction, err := h.photoService.AddReaction(r.Context(), photoID, userID, req.Emoji)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to add reaction")
+		respondServiceError(w, err, "failed to add reaction")
 		return
 	}
 
@@ -149,14 +152,16 @@ func (h *PhotoHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
 
 // RemoveReaction godoc
 // @Summary Remove a reaction from a photo
-// @Description Remove a user's reaction from a photo
+// @Description Remove the authenticated user's reactions from a photo, or only the one with the given emoji
 // @Tags reactions
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Photo ID"
-// @Param user_id query string true "User ID"
+// @Param emoji query string false "Only remove the reaction with this emoji"
 // @Success 204 "No Content"
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /photos/{id}/reactions [delete]
 func (h *PhotoHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
@@ -167,29 +172,73 @@ func (h *PhotoHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userIDStr := r.URL.Query().Get("user_id")
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		respondError(w, http.StatusBadRequest, "invalid user ID")
+	userID, ok := requireUserID(w, r)
+	if !ok {
 		return
 	}
 
-	if err := h.photoService.RemoveReaction(r.Context(), photoID, userID); err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to remove reaction")
+	if err := h.photoService.RemoveReaction(r.Context(), photoID, userID, r.URL.Query().Get("emoji")); err != nil {
+		respondServiceError(w, err, "failed to remove reaction")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// Request/Response types
-type AddReactionRequest struct {
-	UserID string `json:"user_id"`
-	Emoji  string `json:"emoji"`
+// GetReactionSummary godoc
+// @Summary Get the reaction summary of a photo
+// @Description Get the total number of reactions to a photo, the count per emoji (most used first),
+// @Description and whether the authenticated user reacted
+// @Tags reactions
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Success 200 {object} service.ReactionSummary
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/reactions/summary [get]
+func (h *PhotoHandler) GetReactionSummary(w http.ResponseWriter, r *http.Request) {
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	viewerID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := h.photoService.GetReactionSummary(r.Context(), viewerID, photoID)
+	if err != nil {
+		respondServiceError(w, err, "failed to get reaction summary")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, summary)
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// GetAllowedReactions godoc
+// @Summary List the allowed reactions
+// @Description Get the reaction mode (single, stack or burst) and the emojis users can react with.
+// @Description When restricted is false, any single emoji is accepted.
+// @Tags reactions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} service.AllowedReactions
+// @Failure 401 {object} ErrorResponse
+// @Router /reactions/allowed [get]
+func (h *PhotoHandler) GetAllowedReactions(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, h.photoService.AllowedReactions())
+}
+
+// Request/Response types
+type AddReactionRequest struct {
+	Emoji string `json:"emoji"`
 }
 
 // Helper functions
@@ -199,6 +248,23 @@ func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func respondError(w http.ResponseWriter, status int, message string) {
-	respondJSON(w, status, ErrorResponse{Error: message})
+// parsePageParams reads the cursor and limit query parameters.
+// An invalid or missing limit falls back to the service default.
+func parsePageParams(r *http.Request) (string, int32) {
+	var limit int32
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = int32(min(l, service.MaxPageSize))
+	}
+	return r.URL.Query().Get("cursor"), limit
+}
+
+// parseReactionView reads the include query parameter: include=summary
+// returns aggregated reaction counters instead of every reaction
+func parseReactionView(r *http.Request) service.ReactionView {
+	for _, v := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(v) == "summary" {
+			return service.ReactionViewSummary
+		}
+	}
+	return service.ReactionViewFull
 }
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DeletePhoto godoc
+// @Summary Delete a photo
+// @Description Soft-delete a photo sent by the authenticated user. It can be restored within the restore window.
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id} [delete]
+func (h *PhotoHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	if err := h.photoService.DeletePhoto(r.Context(), photoID, userID); err != nil {
+		respondServiceError(w, err, "failed to delete photo")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestorePhoto godoc
+// @Summary Restore a deleted photo
+// @Description Undo the deletion of a photo sent by the authenticated user, within the restore window
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Success 200 {object} service.PhotoResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/restore [post]
+func (h *PhotoHandler) RestorePhoto(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	photo, err := h.photoService.RestorePhoto(r.Context(), photoID, userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to restore photo")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, photo)
+}
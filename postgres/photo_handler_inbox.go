@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// GetInbox godoc
+// @Summary Get the authenticated user's inbox
+// @Description Get a page of the photos sent to the authenticated user with all their reactions, newest first.
+// @Description Hidden photos are left out.
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Param include query string false "summary: return reaction_summary counters instead of the reactions list"
+// @Success 200 {object} service.PhotoPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/inbox [get]
+func (h *PhotoHandler) GetInbox(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.photoService.GetInbox(r.Context(), userID, cursor, limit, parseReactionView(r))
+	if err != nil {
+		respondServiceError(w, err, "failed to get inbox")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// GetFeed godoc
+// @Summary Get the authenticated user's feed
+// @Description Get a page of the photos from the authenticated user's friends with all their reactions, newest first:
+// @Description photos sent to the user and photos shared with all friends. Hidden photos are left out.
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Limit" default(20)
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Param include query string false "summary: return reaction_summary counters instead of the reactions list"
+// @Success 200 {object} service.PhotoPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/feed [get]
+func (h *PhotoHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.photoService.GetFeed(r.Context(), userID, cursor, limit, parseReactionView(r))
+	if err != nil {
+		respondServiceError(w, err, "failed to get feed")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// MarkPhotoViewed godoc
+// @Summary Mark a photo as viewed
+// @Description Record that the authenticated user viewed a photo sent to them
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Success 200 {object} service.RecipientResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/view [post]
+func (h *PhotoHandler) MarkPhotoViewed(w http.ResponseWriter, r *http.Request) {
+	h.updateRecipient(w, r, h.photoService.MarkPhotoViewed)
+}
+
+// HidePhoto godoc
+// @Summary Hide a photo from the inbox
+// @Description Hide a photo sent to the authenticated user from their inbox
+// @Tags photos
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Success 200 {object} service.RecipientResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/hide [post]
+func (h *PhotoHandler) HidePhoto(w http.ResponseWriter, r *http.Request) {
+	h.updateRecipient(w, r, h.photoService.HidePhoto)
+}
+
+// updateRecipient applies update to the caller's delivery of the photo in the {id} path variable
+func (h *PhotoHandler) updateRecipient(w http.ResponseWriter, r *http.Request,
+	update func(ctx context.Context, photoID, userID uuid.UUID) (*service.RecipientResponse, error)) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	recipient, err := update(r.Context(), photoID, userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to update photo delivery")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, recipient)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// CreateReply godoc
+// @Summary Reply to a photo
+// @Description Add a text reply to a photo. Each friend has a private thread with the photo's sender:
+// @Description friends reply in their own thread, the sender sets thread_user_id to choose the friend.
+// @Tags replies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Param request body CreateReplyRequest true "Reply"
+// @Success 201 {object} service.ReplyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/replies [post]
+func (h *PhotoHandler) CreateReply(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	var req CreateReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var threadUserID uuid.UUID
+	if req.ThreadUserID != "" {
+		if threadUserID, err = uuid.Parse(req.ThreadUserID); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid thread user ID")
+			return
+		}
+	}
+
+	reply, err := h.photoService.CreateReply(r.Context(), photoID, userID, threadUserID, req.Body)
+	if err != nil {
+		respondServiceError(w, err, "failed to create reply")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, reply)
+}
+
+// ListReplies godoc
+// @Summary List the replies to a photo
+// @Description Get a page of the replies to a photo, newest first. The sender sees every thread
+// @Description (or one, with thread_user_id); anyone else sees their own thread with the sender.
+// @Tags replies
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Param thread_user_id query string false "Only the thread with this friend (sender only)"
+// @Param limit query int false "Limit" default(20)
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Success 200 {object} service.ReplyPage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/replies [get]
+func (h *PhotoHandler) ListReplies(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return
+	}
+
+	var threadUserID uuid.UUID
+	if v := r.URL.Query().Get("thread_user_id"); v != "" {
+		if threadUserID, err = uuid.Parse(v); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid thread user ID")
+			return
+		}
+	}
+
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.photoService.ListReplies(r.Context(), userID, photoID, threadUserID, cursor, limit)
+	if err != nil {
+		respondServiceError(w, err, "failed to list replies")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// UpdateReply godoc
+// @Summary Edit a reply
+// @Description Change the text of a reply written by the authenticated user
+// @Tags replies
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Param reply_id path string true "Reply ID"
+// @Param request body UpdateReplyRequest true "New text"
+// @Success 200 {object} service.ReplyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/replies/{reply_id} [patch]
+func (h *PhotoHandler) UpdateReply(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, replyID, ok := parseReplyPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	reply, err := h.photoService.UpdateReply(r.Context(), photoID, replyID, userID, req.Body)
+	if err != nil {
+		respondServiceError(w, err, "failed to update reply")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, reply)
+}
+
+// DeleteReply godoc
+// @Summary Delete a reply
+// @Description Delete a reply. Its author and the photo's sender can delete it.
+// @Tags replies
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Photo ID"
+// @Param reply_id path string true "Reply ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/{id}/replies/{reply_id} [delete]
+func (h *PhotoHandler) DeleteReply(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	photoID, replyID, ok := parseReplyPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.photoService.DeleteReply(r.Context(), photoID, replyID, userID); err != nil {
+		respondServiceError(w, err, "failed to delete reply")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseReplyPath reads the photo and reply IDs from the URL, responding 400 if either is invalid
+func parseReplyPath(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	vars := mux.Vars(r)
+	photoID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid photo ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	replyID, err := uuid.Parse(vars["reply_id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid reply ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return photoID, replyID, true
+}
+
+// Request types
+type CreateReplyRequest struct {
+	Body         string `json:"body"`
+	ThreadUserID string `json:"thread_user_id,omitempty"` // required when the sender replies
+}
+
+type UpdateReplyRequest struct {
+	Body string `json:"body"`
+}
@@ -1,12 +1,10 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/yourusername/yourproject/service" // Update with your actual path
 )
 
 // GetUserPhotosSimple godoc
@@ -15,11 +13,14 @@ import (
 // @Tags photos
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param user_id path string true "User ID"
 // @Param limit query int false "Limit" default(20)
-// @Param offset query int false "Offset" default(0)
-// @Success 200 {array} service.SimplePhotoResponse
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Success 200 {object} service.SimplePhotoPage
 // @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /users/{user_id}/photos/simple [get]
 func (h *PhotoHandler) GetUserPhotosSimple(w http.ResponseWriter, r *http.Request) {
@@ -30,29 +31,18 @@ func (h *PhotoHandler) GetUserPhotosSimple(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Parse query parameters
-	limit := int32(20)
-	offset := int32(0)
-	
-	if l := r.URL.Query().Get("limit"); l != "" {
-		var limitInt int
-		if _, err := fmt.Sscanf(l, "%d", &limitInt); err == nil && limitInt > 0 {
-			limit = int32(limitInt)
-		}
-	}
-	
-	if o := r.URL.Query().Get("offset"); o != "" {
-		var offsetInt int
-		if _, err := fmt.Sscanf(o, "%d", &offsetInt); err == nil && offsetInt >= 0 {
-			offset = int32(offsetInt)
-		}
+	viewerID, ok := requireUserID(w, r)
+	if !ok {
+		return
 	}
 
-	photos, err := h.photoService.GetPhotosWithReactionsSimple(r.Context(), userID, limit, offset)
+	cursor, limit := parsePageParams(r)
+
+	page, err := h.photoService.GetPhotosWithReactionsSimple(r.Context(), viewerID, userID, cursor, limit)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, "failed to get photos")
+		respondServiceError(w, err, "failed to get photos")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, photos)
+	respondJSON(w, http.StatusOK, page)
 }
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// multipartMemory is the part of a multipart upload kept in memory before spilling to disk
+const multipartMemory = 8 << 20
+
+// UploadPhoto godoc
+// @Summary Upload a photo
+// @Description Upload a photo as multipart/form-data and create its record, sent by the authenticated user
+// @Tags photos
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param photo formData file true "Photo file"
+// @Param caption formData string false "Caption"
+// @Param expires_at formData string false "Expiry time (RFC 3339)"
+// @Param recipient_ids formData []string false "Friends to send the photo to (repeated or comma-separated); all friends if omitted"
+// @Success 201 {object} service.PhotoResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos [post]
+func (h *PhotoHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	// Leave headroom for the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, service.MaxPhotoSize+1<<20)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		respondError(w, http.StatusRequestEntityTooLarge, "invalid or too large multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "photo file is required")
+		return
+	}
+	defer file.Close()
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		// Fall back to sniffing the first bytes of the file
+		buf := make([]byte, 512)
+		n, _ := file.Read(buf)
+		mimeType = http.DetectContentType(buf[:n])
+		if _, err := file.Seek(0, 0); err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to read photo")
+			return
+		}
+	}
+
+	if !service.IsSupportedPhotoType(mimeType) {
+		respondError(w, http.StatusUnsupportedMediaType, "unsupported photo type")
+		return
+	}
+
+	params := service.CreatePhotoParams{
+		SenderID: senderID,
+		Content:  file,
+		MimeType: mimeType,
+	}
+	if caption := r.FormValue("caption"); caption != "" {
+		params.Caption = &caption
+	}
+	if e := r.FormValue("expires_at"); e != "" {
+		expiresAt, err := time.Parse(time.RFC3339, e)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid expires_at")
+			return
+		}
+		params.ExpiresAt = &expiresAt
+	}
+	recipientIDs, ok := parseRecipientIDs(w, r.MultipartForm.Value["recipient_ids"])
+	if !ok {
+		return
+	}
+	params.RecipientIDs = recipientIDs
+
+	photo, err := h.photoService.CreatePhoto(r.Context(), params)
+	if err != nil {
+		respondServiceError(w, err, "failed to upload photo")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, photo)
+}
+
+// CreateUpload godoc
+// @Summary Start a direct photo upload
+// @Description Get a signed URL to PUT a photo to directly, bypassing the API server.
+// @Description Once uploaded, confirm it with POST /photos/uploads/{id}/complete before expires_at.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateUploadRequest true "The photo to upload"
+// @Success 201 {object} service.UploadResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/uploads [post]
+func (h *PhotoHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	upload, err := h.photoService.CreateUpload(r.Context(), service.CreateUploadParams{
+		SenderID: senderID,
+		MimeType: req.MimeType,
+		FileSize: req.FileSize,
+		SHA256:   req.SHA256,
+	})
+	if err != nil {
+		respondServiceError(w, err, "failed to create upload")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, upload)
+}
+
+// CompleteUpload godoc
+// @Summary Complete a direct photo upload
+// @Description Check that the photo was uploaded with the declared size, sha256 and type and create it.
+// @Description The photo gets the upload's ID.
+// @Tags photos
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Upload ID"
+// @Param request body CompleteUploadRequest false "Photo details"
+// @Success 201 {object} service.PhotoResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /photos/uploads/{id}/complete [post]
+func (h *PhotoHandler) CompleteUpload(w http.ResponseWriter, r *http.Request) {
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	uploadID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid upload ID")
+		return
+	}
+
+	var req CompleteUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	photo, err := h.photoService.CompleteUpload(r.Context(), uploadID, senderID, service.CompleteUploadParams{
+		Caption:      req.Caption,
+		ExpiresAt:    req.ExpiresAt,
+		RecipientIDs: req.RecipientIDs,
+	})
+	if err != nil {
+		respondServiceError(w, err, "failed to complete upload")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, photo)
+}
+
+// parseRecipientIDs parses recipient_ids form values, which may be repeated or comma-separated.
+// It responds 400 if any ID is invalid.
+func parseRecipientIDs(w http.ResponseWriter, values []string) ([]uuid.UUID, bool) {
+	var ids []uuid.UUID
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "invalid recipient ID")
+				return nil, false
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, true
+}
+
+// Request types
+type CreateUploadRequest struct {
+	MimeType string `json:"mime_type"`
+	FileSize int64  `json:"file_size"` // in bytes
+	SHA256   string `json:"sha256"`    // hex-encoded
+}
+
+type CompleteUploadRequest struct {
+	Caption      *string     `json:"caption,omitempty"`
+	ExpiresAt    *time.Time  `json:"expires_at,omitempty"`
+	RecipientIDs []uuid.UUID `json:"recipient_ids,omitempty"` // all friends if omitted
+}
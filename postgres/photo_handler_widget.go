@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// GetWidget godoc
+// @Summary Get the home-screen widget photo
+// @Description Get the newest photo of the authenticated user's feed in a compact form for home-screen widgets,
+// @Description shaped by their widget settings. Send the ETag back in If-None-Match to get 304 while nothing changed.
+// @Tags widget
+// @Produce json
+// @Security BearerAuth
+// @Param If-None-Match header string false "ETag of the widget payload the client has"
+// @Success 200 {object} service.WidgetResponse
+// @Success 304
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/widget [get]
+func (h *PhotoHandler) GetWidget(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	widget, err := h.photoService.GetWidget(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to get widget")
+		return
+	}
+
+	w.Header().Set("ETag", widget.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), widget.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, widget)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing weakly
+// as RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWidgetSettings godoc
+// @Summary Get widget settings
+// @Description Get the home-screen widget preferences of the authenticated user
+// @Tags widget
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} service.WidgetSettings
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/widget/settings [get]
+func (h *PhotoHandler) GetWidgetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := h.photoService.GetWidgetSettings(r.Context(), userID)
+	if err != nil {
+		respondServiceError(w, err, "failed to get widget settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// UpdateWidgetSettings godoc
+// @Summary Update widget settings
+// @Description Replace the home-screen widget preferences of the authenticated user
+// @Tags widget
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body service.WidgetSettings true "Widget settings"
+// @Success 200 {object} service.WidgetSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /me/widget/settings [put]
+func (h *PhotoHandler) UpdateWidgetSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req service.WidgetSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	settings, err := h.photoService.UpdateWidgetSettings(r.Context(), userID, req)
+	if err != nil {
+		respondServiceError(w, err, "failed to update widget settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
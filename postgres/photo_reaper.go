@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// expiryBatchSize is the number of expired photos reaped per query
+const expiryBatchSize = 100
+
+// ReapExpiredPhotos marks photos past their expires_at deleted and removes their
+// stored objects. It returns the number of photos reaped.
+func (s *PhotoService) ReapExpiredPhotos(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		rows, err := s.queries.ExpirePhotos(ctx, expiryBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to expire photos: %w", err)
+		}
+		for _, row := range rows {
+			if row.Key != nil {
+				s.deleteObjects(ctx, *row.Key)
+			}
+		}
+		total += len(rows)
+		if len(rows) < expiryBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunExpiryReaper reaps expired photos every interval until ctx is canceled
+func (s *PhotoService) RunExpiryReaper(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		n, err := s.ReapExpiredPhotos(ctx)
+		if err != nil {
+			log.Printf("expiry reaper: %v", err)
+		}
+		if n > 0 {
+			log.Printf("expiry reaper: reaped %d expired photos", n)
+		}
+	})
+}
+
+// runEvery calls fn immediately and then every interval until ctx is canceled
+func runEvery(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
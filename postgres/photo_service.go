@@ -2,21 +2,24 @@ package service
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yourusername/yourproject/db" // Update with your actual path
 )
 
 // ReactionResponse represents a reaction in the API response
 type ReactionResponse struct {
-	ID        uuid.UUID `json:"id"`
-	PhotoID   uuid.UUID `json:"photo_id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Emoji     string    `json:"emoji"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uuid.UUID `json:"id"`
+	PhotoID    uuid.UUID `json:"photo_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Emoji      string    `json:"emoji"`
+	CreatedAt  time.Time `json:"created_at"`
+	BurstCount int32     `json:"burst_count"` // how often the user repeated it, in burst mode
 }
 
 // PhotoResponse represents a photo with its reactions in the API response
@@ -35,31 +38,102 @@ type PhotoResponse struct {
 	CreatedAt    *time.Time         `json:"created_at"`
 	ExpiresAt    *time.Time         `json:"expires_at,omitempty"`
 	Key          *string            `json:"key,omitempty"`
-	Reactions    []ReactionResponse `json:"reactions"` // Always include, empty if no reactions
+	Reactions    []ReactionResponse `json:"reactions"` // Always include, empty if no reactions; null in the summary view
+	// ReactionSummary replaces Reactions when a list is requested with ReactionViewSummary
+	ReactionSummary *ReactionSummary `json:"reaction_summary,omitempty"`
+	// ReplyCount is the number of replies the viewer can see, set when reading photos
+	ReplyCount *int64 `json:"reply_count,omitempty"`
 }
 
 // PhotoService handles business logic for photos
 type PhotoService struct {
-	queries *db.Queries
+	pool           *pgxpool.Pool
+	queries        *db.Queries
+	blobs          BlobStore
+	thumbnailer    *Thumbnailer
+	retention      RetentionPolicy
+	events         EventPublisher
+	reactionPolicy reactionPolicy
+	urls           URLSigner
+	urlTTL         time.Duration
+	uploads        UploadSigner
+	uploadTTL      time.Duration
 }
 
 // NewPhotoService creates a new photo service
-func NewPhotoService(queries *db.Queries) *PhotoService {
-	return &PhotoService{queries: queries}
+func NewPhotoService(pool *pgxpool.Pool, queries *db.Queries, blobs BlobStore) *PhotoService {
+	return &PhotoService{
+		pool:           pool,
+		queries:        queries,
+		blobs:          blobs,
+		thumbnailer:    NewThumbnailer(DefaultThumbnailVariants),
+		retention:      DefaultRetentionPolicy,
+		events:         nopPublisher{},
+		reactionPolicy: reactionPolicy{mode: ReactionModeSingle},
+		urlTTL:         DefaultMediaURLTTL,
+		uploadTTL:      DefaultUploadTTL,
+	}
+}
+
+// withTx runs fn inside a database transaction, committing if fn returns nil
+func (s *PhotoService) withTx(ctx context.Context, fn func(q *db.Queries) error) error {
+	return withTx(ctx, s.pool, s.queries, fn)
+}
+
+// checkPhotoVisible returns ErrForbidden unless viewerID may see the photo:
+// the sender always can, anyone else must be an accepted friend and a recipient
+func (s *PhotoService) checkPhotoVisible(ctx context.Context, viewerID, photoID uuid.UUID) error {
+	canView, err := s.queries.CanViewPhoto(ctx, db.CanViewPhotoParams{ViewerID: viewerID, PhotoID: photoID})
+	if err != nil {
+		return s.photoLookupError(ctx, photoID, err, "check photo visibility")
+	}
+	if !canView {
+		return fmt.Errorf("%w: this photo was not sent to you", ErrForbidden)
+	}
+	return nil
+}
+
+// photoLookupError maps an error from looking up a photo. A photo that is missing
+// because it has expired is reported as ErrExpired rather than not found.
+func (s *PhotoService) photoLookupError(ctx context.Context, photoID uuid.UUID, err error, op string) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return mapDBError(err, "photo", op)
+	}
+	expired, expErr := s.queries.IsPhotoExpired(ctx, photoID)
+	if expErr == nil && expired {
+		return fmt.Errorf("%w: photo has expired", ErrExpired)
+	}
+	return &NotFoundError{Resource: "photo"}
+}
+
+// checkVisible returns ErrForbidden unless viewerID may see the photos of senderID.
+// Senders always see their own photos; everyone else must be an accepted friend.
+func (s *PhotoService) checkVisible(ctx context.Context, viewerID, senderID uuid.UUID) error {
+	if viewerID == senderID {
+		return nil
+	}
+	friends, err := s.queries.AreFriends(ctx, db.AreFriendsParams{UserA: viewerID, UserB: senderID})
+	if err != nil {
+		return fmt.Errorf("failed to check friendship: %w", err)
+	}
+	if !friends {
+		return fmt.Errorf("%w: only friends of the sender can see their photos", ErrForbidden)
+	}
+	return nil
 }
 
 // APPROACH 1: Two-Query Approach (More Flexible, Easier to Understand)
 // Best for: Simple use cases, when you need fine-grained control
 
-// GetPhotoWithReactionsTwoQueries fetches a photo and its reactions using two queries
-func (s *PhotoService) GetPhotoWithReactionsTwoQueries(ctx context.Context, photoID uuid.UUID) (*PhotoResponse, error) {
+// GetPhotoWithReactionsTwoQueries fetches a photo visible to viewerID and its reactions using two queries
+func (s *PhotoService) GetPhotoWithReactionsTwoQueries(ctx context.Context, viewerID, photoID uuid.UUID) (*PhotoResponse, error) {
 	// Query 1: Get the photo
 	photo, err := s.queries.GetPhotoByID(ctx, photoID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("photo not found")
-		}
-		return nil, fmt.Errorf("failed to get photo: %w", err)
+		return nil, s.photoLookupError(ctx, photoID, err, "get photo")
+	}
+	if err := s.checkPhotoVisible(ctx, viewerID, photo.ID); err != nil {
+		return nil, err
 	}
 
 	// Query 2: Get reactions for the photo
@@ -89,23 +163,20 @@ func (s *PhotoService) GetPhotoWithReactionsTwoQueries(ctx context.Context, phot
 
 	// Convert reactions to response format
 	for _, r := range reactions {
-		response.Reactions = append(response.Reactions, ReactionResponse{
-			ID:        r.ID,
-			PhotoID:   r.PhotoID,
-			UserID:    r.UserID,
-			Emoji:     r.Emoji,
-			CreatedAt: r.CreatedAt,
-		})
+		response.Reactions = append(response.Reactions, newReactionResponse(r))
 	}
 
-	return response, nil
+	if err := s.signPhotoURL(ctx, response); err != nil {
+		return nil, err
+	}
+	return s.withReplyCount(ctx, viewerID, response)
 }
 
 // APPROACH 2: Single-Query with LEFT JOIN (More Efficient for Database)
 // Best for: High-performance needs, reducing database round trips
 
-// GetPhotoWithReactionsSingleQuery fetches a photo and its reactions using a single optimized query
-func (s *PhotoService) GetPhotoWithReactionsSingleQuery(ctx context.Context, photoID uuid.UUID) (*PhotoResponse, error) {
+// GetPhotoWithReactionsSingleQuery fetches a photo visible to viewerID and its reactions using a single optimized query
+func (s *PhotoService) GetPhotoWithReactionsSingleQuery(ctx context.Context, viewerID, photoID uuid.UUID) (*PhotoResponse, error) {
 	// Single query with LEFT JOIN
 	rows, err := s.queries.GetPhotoWithReactionsOptimized(ctx, photoID)
 	if err != nil {
@@ -113,11 +184,14 @@ func (s *PhotoService) GetPhotoWithReactionsSingleQuery(ctx context.Context, pho
 	}
 
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("photo not found")
+		return nil, s.photoLookupError(ctx, photoID, pgx.ErrNoRows, "get photo")
 	}
 
 	// Build response from the first row (photo data)
 	firstRow := rows[0]
+	if err := s.checkPhotoVisible(ctx, viewerID, firstRow.PhotoID); err != nil {
+		return nil, err
+	}
 	response := &PhotoResponse{
 		ID:           firstRow.PhotoID,
 		SenderID:     firstRow.SenderID,
@@ -145,178 +219,308 @@ func (s *PhotoService) GetPhotoWithReactionsSingleQuery(ctx context.Context, pho
 			reactionUserID, _ := uuid.FromBytes(row.ReactionUserID.Bytes[:])
 			
 			response.Reactions = append(response.Reactions, ReactionResponse{
-				ID:        reactionID,
-				PhotoID:   response.ID,
-				UserID:    reactionUserID,
-				Emoji:     row.ReactionEmoji,
-				CreatedAt: row.ReactionCreatedAt.Time,
+				ID:         reactionID,
+				PhotoID:    response.ID,
+				UserID:     reactionUserID,
+				Emoji:      row.ReactionEmoji,
+				CreatedAt:  row.ReactionCreatedAt.Time,
+				BurstCount: row.ReactionBurstCount.Int32,
 			})
 		}
 	}
 
-	return response, nil
+	if err := s.signPhotoURL(ctx, response); err != nil {
+		return nil, err
+	}
+	return s.withReplyCount(ctx, viewerID, response)
 }
 
-// GetPhotosByUserWithReactions fetches all photos by a user with their reactions
-func (s *PhotoService) GetPhotosByUserWithReactions(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]PhotoResponse, error) {
-	rows, err := s.queries.GetPhotosWithReactionsByUserID(ctx, db.GetPhotosWithReactionsByUserIDParams{
-		SenderID: userID,
-		Limit:    limit,
-		Offset:   offset,
+// PhotoPage is one page of photos with the cursor for the next page
+type PhotoPage struct {
+	Photos     []PhotoResponse `json:"photos"`
+	NextCursor *string         `json:"next_cursor"` // null on the last page
+}
+
+// GetPhotosByUserWithReactions fetches one page of a user's photos with their reactions in the given view.
+// Photos are paginated first (newest first, keyed on created_at and id) and their
+// reactions attached afterwards, so a page never splits one photo's reactions.
+func (s *PhotoService) GetPhotosByUserWithReactions(ctx context.Context, viewerID, userID uuid.UUID, cursor string, limit int32, view ReactionView) (*PhotoPage, error) {
+	photos, nextCursor, err := s.listPhotosByUser(ctx, viewerID, userID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.withReactions(ctx, viewerID, photos, view)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PhotoPage{Photos: responses, NextCursor: nextCursor}, nil
+}
+
+// listPhotosByUser fetches one page of a user's photos visible to viewerID and the cursor of the next page
+func (s *PhotoService) listPhotosByUser(ctx context.Context, viewerID, userID uuid.UUID, cursor string, limit int32) ([]db.Photo, *string, error) {
+	if err := s.checkVisible(ctx, viewerID, userID); err != nil {
+		return nil, nil, err
+	}
+
+	ks, err := parseKeyset(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limit = clampPageSize(limit)
+	photos, err := s.queries.ListPhotosByUserID(ctx, db.ListPhotosByUserIDParams{
+		SenderID:        userID,
+		ViewerID:        viewerID,
+		HasCursor:       ks.HasCursor,
+		CursorCreatedAt: ks.CreatedAt,
+		CursorID:        ks.ID,
+		PageSize:        limit + 1, // one extra row tells us whether there is a next page
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get photos with reactions: %w", err)
+		return nil, nil, fmt.Errorf("failed to list photos: %w", err)
 	}
 
-	// Group by photo ID
-	photoMap := make(map[uuid.UUID]*PhotoResponse)
-	var photoOrder []uuid.UUID // Track order
+	photos, nextCursor := paginate(photos, limit)
+	return photos, nextCursor, nil
+}
 
-	for _, row := range rows {
-		photoID := row.PhotoID
-
-		// Create photo response if not exists
-		if _, exists := photoMap[photoID]; !exists {
-			photoMap[photoID] = &PhotoResponse{
-				ID:           row.PhotoID,
-				SenderID:     row.SenderID,
-				PhotoURL:     row.PhotoURL,
-				ThumbnailURL: row.ThumbnailURL,
-				FileSize:     row.FileSize,
-				Width:        row.Width,
-				Height:       row.Height,
-				MimeType:     row.MimeType,
-				Caption:      row.Caption,
-				IsDeleted:    row.IsDeleted,
-				DeletedAt:    row.DeletedAt,
-				CreatedAt:    &row.PhotoCreatedAt,
-				ExpiresAt:    row.ExpiresAt,
-				Key:          row.Key,
-				Reactions:    make([]ReactionResponse, 0),
-			}
-			photoOrder = append(photoOrder, photoID)
-		}
+// paginate trims a result fetched with limit+1 rows to limit rows and
+// returns the cursor of the next page, or nil if this is the last page
+func paginate(photos []db.Photo, limit int32) ([]db.Photo, *string) {
+	if int32(len(photos)) <= limit {
+		return photos, nil
+	}
+	photos = photos[:limit]
+	last := photos[len(photos)-1]
+	var createdAt time.Time
+	if last.CreatedAt != nil {
+		createdAt = *last.CreatedAt
+	}
+	next := encodeCursor(createdAt, last.ID)
+	return photos, &next
+}
 
-		// Add reaction if exists
-		// Check if reaction_id is valid (not NULL) using pgtype.UUID.Valid field
-		if row.ReactionID.Valid {
-			reactionID, _ := uuid.FromBytes(row.ReactionID.Bytes[:])
-			reactionUserID, _ := uuid.FromBytes(row.ReactionUserID.Bytes[:])
-			
-			photoMap[photoID].Reactions = append(photoMap[photoID].Reactions, ReactionResponse{
-				ID:        reactionID,
-				PhotoID:   photoID,
-				UserID:    reactionUserID,
-				Emoji:     row.ReactionEmoji,
-				CreatedAt: row.ReactionCreatedAt.Time,
-			})
-		}
+// attachReactions loads the reactions of all photos with one query and
+// groups them by photo, preserving the order of photos
+func (s *PhotoService) attachReactions(ctx context.Context, photos []db.Photo) ([]PhotoResponse, error) {
+	result := make([]PhotoResponse, 0, len(photos))
+	if len(photos) == 0 {
+		return result, nil
 	}
 
-	// Convert map to ordered slice
-	result := make([]PhotoResponse, 0, len(photoOrder))
-	for _, photoID := range photoOrder {
-		result = append(result, *photoMap[photoID])
+	photoIDs := make([]uuid.UUID, 0, len(photos))
+	for _, p := range photos {
+		photoIDs = append(photoIDs, p.ID)
 	}
 
+	reactions, err := s.queries.GetReactionsByPhotoIDs(ctx, photoIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %w", err)
+	}
+
+	// Group by photo ID
+	reactionsByPhoto := make(map[uuid.UUID][]ReactionResponse, len(photos))
+	for _, r := range reactions {
+		reactionsByPhoto[r.PhotoID] = append(reactionsByPhoto[r.PhotoID], newReactionResponse(r))
+	}
+
+	for _, p := range photos {
+		response := newPhotoResponse(p)
+		if rs, ok := reactionsByPhoto[p.ID]; ok {
+			response.Reactions = rs
+		}
+		result = append(result, response)
+	}
+	if err := s.signPhotoURLs(ctx, result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-// AddReaction adds or updates a reaction to a photo
+// newPhotoResponse converts a photo row to its API response with an empty reactions list
+func newPhotoResponse(p db.Photo) PhotoResponse {
+	return PhotoResponse{
+		ID:           p.ID,
+		SenderID:     p.SenderID,
+		PhotoURL:     p.PhotoURL,
+		ThumbnailURL: p.ThumbnailURL,
+		FileSize:     p.FileSize,
+		Width:        p.Width,
+		Height:       p.Height,
+		MimeType:     p.MimeType,
+		Caption:      p.Caption,
+		IsDeleted:    p.IsDeleted,
+		DeletedAt:    p.DeletedAt,
+		CreatedAt:    p.CreatedAt,
+		ExpiresAt:    p.ExpiresAt,
+		Key:          p.Key,
+		Reactions:    make([]ReactionResponse, 0), // Initialize empty slice
+	}
+}
+
+// newReactionResponse converts a reaction row to its API response
+func newReactionResponse(r db.Reaction) ReactionResponse {
+	return ReactionResponse{
+		ID:         r.ID,
+		PhotoID:    r.PhotoID,
+		UserID:     r.UserID,
+		Emoji:      r.Emoji,
+		CreatedAt:  r.CreatedAt,
+		BurstCount: r.BurstCount,
+	}
+}
+
+// AddReaction adds a reaction to a photo. Only users who can see the photo may react
+// to it, with a single allowed emoji. What happens to the user's other reactions and
+// to repeats of the same emoji depends on the ReactionMode.
 func (s *PhotoService) AddReaction(ctx context.Context, photoID, userID uuid.UUID, emoji string) (*ReactionResponse, error) {
-	reaction, err := s.queries.CreateReaction(ctx, db.CreateReactionParams{
-		PhotoID: photoID,
-		UserID:  userID,
-		Emoji:   emoji,
-	})
+	emoji, err := s.checkEmoji(emoji)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create reaction: %w", err)
+		return nil, err
 	}
 
-	return &ReactionResponse{
-		ID:        reaction.ID,
-		PhotoID:   reaction.PhotoID,
-		UserID:    reaction.UserID,
-		Emoji:     reaction.Emoji,
-		CreatedAt: reaction.CreatedAt,
-	}, nil
-}
+	if err := s.checkPhotoVisible(ctx, userID, photoID); err != nil {
+		return nil, err
+	}
+
+	mode := s.reactionPolicy.mode
+	var response ReactionResponse
+	var events []Event
+	err = s.withTx(ctx, func(q *db.Queries) error {
+		var replaced []db.Reaction
+		if mode == ReactionModeSingle {
+			// Without the lock, concurrent requests with different emoji would each
+			// delete the other's reaction before it exists and both insert theirs
+			err := q.LockUserReactions(ctx, db.LockUserReactionsParams{
+				PhotoID: photoID,
+				UserID:  userID,
+			})
+			if err != nil {
+				return mapDBError(err, "reaction", "lock reactions")
+			}
+			replaced, err = q.DeleteOtherReactions(ctx, db.DeleteOtherReactionsParams{
+				PhotoID: photoID,
+				UserID:  userID,
+				Emoji:   emoji,
+			})
+			if err != nil {
+				return mapDBError(err, "reaction", "replace reaction")
+			}
+		}
+
+		added := &response
+		reaction, err := q.CreateReaction(ctx, db.CreateReactionParams{
+			PhotoID:  photoID,
+			UserID:   userID,
+			Emoji:    emoji,
+			Burst:    mode == ReactionModeBurst,
+			MaxBurst: MaxBurstCount,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The user already reacted with this emoji, so there is no reaction to announce
+			added = nil
+			reaction, err = q.GetUserReaction(ctx, db.GetUserReactionParams{
+				PhotoID: photoID,
+				UserID:  userID,
+				Emoji:   emoji,
+			})
+		}
+		if err != nil {
+			return mapDBError(err, "reaction", "create reaction")
+		}
 
-// RemoveReaction removes a user's reaction from a photo
-func (s *PhotoService) RemoveReaction(ctx context.Context, photoID, userID uuid.UUID) error {
-	err := s.queries.DeleteReaction(ctx, db.DeleteReactionParams{
-		PhotoID: photoID,
-		UserID:  userID,
+		response = newReactionResponse(reaction)
+		events, err = s.recordReactionEvents(ctx, q, photoID, userID, replaced, added)
+		return err
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete reaction: %w", err)
+		return nil, err
 	}
-	return nil
+
+	for _, e := range events {
+		s.publish(ctx, e)
+	}
+	return &response, nil
 }
 
-// GetPhotosWithReactionsComplete fetches all photos by a user with complete photo and reaction data
-// This uses a single optimized query with LEFT JOIN to include all fields
-func (s *PhotoService) GetPhotosWithReactionsComplete(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]PhotoResponse, error) {
-	rows, err := s.queries.GetPhotosWithReactionsComplete(ctx, db.GetPhotosWithReactionsCompleteParams{
-		SenderID: userID,
-		Limit:    limit,
-		Offset:   offset,
+// RemoveReaction removes a user's reactions from a photo, or only the one with emoji
+// when it is not empty. It only ever deletes the caller's own reactions, so it needs
+// no visibility check: users who are no longer friends with the sender can still
+// take theirs back.
+func (s *PhotoService) RemoveReaction(ctx context.Context, photoID, userID uuid.UUID, emoji string) error {
+	if emoji != "" {
+		// Match the form AddReaction stored; reactions no longer allowed can still be removed
+		if stored, err := s.checkEmoji(emoji); err == nil {
+			emoji = stored
+		}
+	}
+
+	var events []Event
+	err := s.withTx(ctx, func(q *db.Queries) error {
+		removed, err := q.DeleteReactions(ctx, db.DeleteReactionsParams{
+			PhotoID: photoID,
+			UserID:  userID,
+			Emoji:   emoji,
+		})
+		if err != nil {
+			return mapDBError(err, "reaction", "delete reaction")
+		}
+
+		events, err = s.recordReactionEvents(ctx, q, photoID, userID, removed, nil)
+		return err
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get photos with reactions: %w", err)
+		return err
 	}
 
-	// Group by photo ID
-	photoMap := make(map[uuid.UUID]*PhotoResponse)
-	var photoOrder []uuid.UUID // Track order
+	for _, e := range events {
+		s.publish(ctx, e)
+	}
+	return nil
+}
 
-	for _, row := range rows {
-		photoID := row.PhotoID
-
-		// Create photo response if not exists
-		if _, exists := photoMap[photoID]; !exists {
-			photoMap[photoID] = &PhotoResponse{
-				ID:           row.PhotoID,
-				SenderID:     row.SenderID,
-				PhotoURL:     row.PhotoURL,
-				ThumbnailURL: row.ThumbnailURL,
-				FileSize:     row.FileSize,
-				Width:        row.Width,
-				Height:       row.Height,
-				MimeType:     row.MimeType,
-				Caption:      row.Caption,
-				IsDeleted:    row.IsDeleted,
-				DeletedAt:    row.DeletedAt,
-				CreatedAt:    &row.PhotoCreatedAt,
-				ExpiresAt:    row.ExpiresAt,
-				Key:          row.Key,
-				Reactions:    make([]ReactionResponse, 0), // Initialize empty slice
-			}
-			photoOrder = append(photoOrder, photoID)
+// recordReactionEvents records a removal event for each of removed and then, unless
+// added is nil, an event for the added reaction, looking up the photo's audience once
+func (s *PhotoService) recordReactionEvents(ctx context.Context, q *db.Queries, photoID, userID uuid.UUID, removed []db.Reaction, added *ReactionResponse) ([]Event, error) {
+	events := make([]Event, 0, len(removed)+1)
+	var audience Event
+	record := func(e Event) error {
+		e.SenderID, e.RecipientIDs = audience.SenderID, audience.RecipientIDs
+		recorded, err := s.recordPhotoEvent(ctx, q, e)
+		if err != nil {
+			return err
 		}
+		audience = recorded
+		events = append(events, recorded)
+		return nil
+	}
 
-		// Add reaction if exists (LEFT JOIN might return NULL)
-		// Check if reaction_id is valid (not NULL) using pgtype.UUID.Valid field
-		if row.ReactionID.Valid {
-			reactionID, _ := uuid.FromBytes(row.ReactionID.Bytes[:])
-			reactionPhotoID, _ := uuid.FromBytes(row.ReactionPhotoID.Bytes[:])
-			reactionUserID, _ := uuid.FromBytes(row.ReactionUserID.Bytes[:])
-			
-			photoMap[photoID].Reactions = append(photoMap[photoID].Reactions, ReactionResponse{
-				ID:        reactionID,
-				PhotoID:   reactionPhotoID,
-				UserID:    reactionUserID,
-				Emoji:     row.ReactionEmoji,
-				CreatedAt: row.ReactionCreatedAt.Time,
-			})
+	for _, r := range removed {
+		response := newReactionResponse(r)
+		err := record(Event{
+			Type:     EventReactionRemoved,
+			PhotoID:  photoID,
+			ActorID:  userID,
+			Reaction: &response,
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Convert map to ordered slice
-	result := make([]PhotoResponse, 0, len(photoOrder))
-	for _, photoID := range photoOrder {
-		result = append(result, *photoMap[photoID])
+	if added != nil {
+		err := record(Event{
+			Type:      EventReactionAdded,
+			PhotoID:   photoID,
+			ActorID:   userID,
+			Reaction:  added,
+			CreatedAt: added.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	return result, nil
+	return events, nil
 }
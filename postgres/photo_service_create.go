@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder for image.Decode
+	_ "image/jpeg" // register JPEG decoder for image.Decode
+	_ "image/png"  // register PNG decoder for image.Decode
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// MaxPhotoSize is the largest photo accepted for upload (in bytes)
+const MaxPhotoSize = 20 << 20
+
+// photoExtensions maps supported mime types to object key extensions
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/heic": ".heic",
+	"image/webp": ".webp",
+}
+
+// IsSupportedPhotoType reports whether photos of the given mime type can be uploaded
+func IsSupportedPhotoType(mimeType string) bool {
+	_, ok := photoExtensions[mimeType]
+	return ok
+}
+
+// CreatePhotoParams holds the input for creating a photo
+type CreatePhotoParams struct {
+	SenderID  uuid.UUID
+	Content   io.Reader
+	MimeType  string
+	Caption   *string
+	ExpiresAt *time.Time
+	// RecipientIDs are the friends the photo is sent to.
+	// An empty list sends the photo to all of the sender's current friends.
+	RecipientIDs []uuid.UUID
+}
+
+// CreatePhoto stores the photo bytes in the blob store and records the photo metadata.
+// The row is inserted in a transaction that is only committed once the object is stored,
+// so a failed upload never leaves a photo pointing at a missing object.
+func (s *PhotoService) CreatePhoto(ctx context.Context, params CreatePhotoParams) (*PhotoResponse, error) {
+	data, err := io.ReadAll(io.LimitReader(params.Content, MaxPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read photo: %w", err)
+	}
+	// The stored type is the one sniffed from the content; the declared type is only a hint
+	photo, err := inspectPhoto(data)
+	if err != nil {
+		return nil, err
+	}
+
+	photoID := uuid.New()
+	return s.savePhoto(ctx, params, photoID, photoKey(params.SenderID, photoID, photoExtensions[photo.MimeType]), photo, nil)
+}
+
+// photoKey returns the key a photo is stored under
+func photoKey(senderID, photoID uuid.UUID, ext string) string {
+	return fmt.Sprintf("photos/%s/%s%s", senderID, photoID, ext)
+}
+
+// completedUpload is the upload that provided the bytes of a new photo
+type completedUpload struct {
+	// consume marks the upload used in the photo's transaction, so only one
+	// completion of the upload can create the photo
+	consume func(q *db.Queries) error
+}
+
+// savePhoto generates the thumbnails of an inspected photo and records it together
+// with the original stored under key. upload is the upload the bytes came from, if any.
+// The recorded type, size and dimensions are the verified ones, not the client's.
+func (s *PhotoService) savePhoto(ctx context.Context, params CreatePhotoParams, photoID uuid.UUID, key string, info *photoInfo, upload *completedUpload) (*PhotoResponse, error) {
+	data := info.Data
+	fileSize := int32(len(data))
+	width, height := int32(info.Width), int32(info.Height)
+	mimeType := info.MimeType
+
+	// Formats without a registered decoder (e.g. HEIC, WebP) are stored without thumbnails.
+	// inspectPhoto capped the pixel count from the header, which bounds the decode.
+	var thumbs []Thumbnail
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		thumbs, err = s.thumbnailer.Generate(key, img, info.Orientation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var thumbnailURL *string
+	for _, t := range thumbs {
+		if t.Variant == PrimaryThumbnailVariant {
+			u := s.blobs.URL(t.Key)
+			thumbnailURL = &u
+		}
+	}
+
+	var response PhotoResponse
+	var event Event
+	err := s.withTx(ctx, func(q *db.Queries) error {
+		if upload != nil {
+			if err := upload.consume(q); err != nil {
+				return err
+			}
+		}
+
+		recipientIDs, err := resolveRecipients(ctx, q, params.SenderID, params.RecipientIDs)
+		if err != nil {
+			return err
+		}
+
+		photo, err := q.CreatePhoto(ctx, db.CreatePhotoParams{
+			ID:           photoID,
+			SenderID:     params.SenderID,
+			PhotoURL:     s.blobs.URL(key),
+			ThumbnailURL: thumbnailURL,
+			FileSize:     &fileSize,
+			Width:        &width,
+			Height:       &height,
+			MimeType:     &mimeType,
+			Caption:      params.Caption,
+			ExpiresAt:    params.ExpiresAt,
+			Key:          &key,
+		})
+		if err != nil {
+			return mapDBError(err, "photo", "create photo")
+		}
+
+		if len(recipientIDs) > 0 {
+			err = q.AddPhotoRecipients(ctx, db.AddPhotoRecipientsParams{
+				PhotoID:      photoID,
+				RecipientIds: recipientIDs,
+			})
+			if err != nil {
+				return mapDBError(err, "recipient", "add photo recipients")
+			}
+		}
+
+		response = newPhotoResponse(photo)
+		event, err = s.recordPhotoEvent(ctx, q, Event{
+			Type:         EventPhotoCreated,
+			PhotoID:      photo.ID,
+			SenderID:     photo.SenderID,
+			ActorID:      photo.SenderID,
+			Photo:        &response,
+			RecipientIDs: recipientIDs,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(data), int64(len(data)), mimeType); err != nil {
+			return fmt.Errorf("failed to store photo: %w", err)
+		}
+		for _, t := range thumbs {
+			if err := s.blobs.Put(ctx, t.Key, bytes.NewReader(t.Data), int64(len(t.Data)), "image/jpeg"); err != nil {
+				return fmt.Errorf("failed to store %s thumbnail: %w", t.Variant, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Objects may have been stored before the commit failed. The objects of an
+		// upload are left to its collector: another completion of the same upload
+		// may have stored them.
+		if upload == nil {
+			s.deleteObjects(context.WithoutCancel(ctx), key)
+		}
+		return nil, err
+	}
+
+	// The photo is stored; clients can still fetch it if signing its URLs fails
+	if err := s.signPhotoURL(ctx, &response); err != nil {
+		log.Printf("failed to sign URLs of photo %s: %v", photoID, err)
+	}
+	s.publish(ctx, event)
+	return &response, nil
+}
+
+// recipientStore is the part of db.Queries used to resolve the recipients of a photo
+type recipientStore interface {
+	ListFriends(ctx context.Context, userID uuid.UUID) ([]db.ListFriendsRow, error)
+	CountFriendsAmong(ctx context.Context, arg db.CountFriendsAmongParams) (int64, error)
+}
+
+// resolveRecipients validates the requested recipients of a photo, who must all be
+// accepted friends of the sender. No recipients means all of the sender's friends.
+func resolveRecipients(ctx context.Context, q recipientStore, senderID uuid.UUID, requested []uuid.UUID) ([]uuid.UUID, error) {
+	if len(requested) == 0 {
+		friends, err := q.ListFriends(ctx, senderID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list friends: %w", err)
+		}
+		recipientIDs := make([]uuid.UUID, 0, len(friends))
+		for _, f := range friends {
+			recipientIDs = append(recipientIDs, f.FriendID)
+		}
+		return recipientIDs, nil
+	}
+
+	seen := make(map[uuid.UUID]bool, len(requested))
+	recipientIDs := make([]uuid.UUID, 0, len(requested))
+	for _, id := range requested {
+		if id == senderID {
+			return nil, &ValidationError{Field: "recipient_ids", Message: "cannot send a photo to yourself"}
+		}
+		if !seen[id] {
+			seen[id] = true
+			recipientIDs = append(recipientIDs, id)
+		}
+	}
+
+	count, err := q.CountFriendsAmong(ctx, db.CountFriendsAmongParams{
+		UserID:    senderID,
+		FriendIds: recipientIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check recipients: %w", err)
+	}
+	if count != int64(len(recipientIDs)) {
+		return nil, &ValidationError{Field: "recipient_ids", Message: "photos can only be sent to friends"}
+	}
+	return recipientIDs, nil
+}
+
+// deleteObjects removes the photo stored under key together with its thumbnails.
+// Failures are logged: a leftover object is harmless, a failed request is not.
+func (s *PhotoService) deleteObjects(ctx context.Context, key string) {
+	for _, k := range append([]string{key}, s.thumbnailer.Keys(key)...) {
+		if err := s.blobs.Delete(ctx, k); err != nil {
+			log.Printf("failed to delete object %s: %v", k, err)
+		}
+	}
+}
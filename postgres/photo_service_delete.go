@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// RetentionPolicy controls how long deleted photos are kept
+type RetentionPolicy struct {
+	// RestoreWindow is how long after deletion the sender can restore a photo
+	RestoreWindow time.Duration
+	// PurgeAfter is how long after deletion a photo and its files are removed for good.
+	// It is never shorter than RestoreWindow.
+	PurgeAfter time.Duration
+}
+
+// DefaultRetentionPolicy keeps deleted photos restorable for a week and purges them after 30 days
+var DefaultRetentionPolicy = RetentionPolicy{
+	RestoreWindow: 7 * 24 * time.Hour,
+	PurgeAfter:    30 * 24 * time.Hour,
+}
+
+// purgeBatchSize is the number of deleted photos purged per query
+const purgeBatchSize = 100
+
+// SetRetentionPolicy replaces the default retention policy
+func (s *PhotoService) SetRetentionPolicy(p RetentionPolicy) {
+	p.PurgeAfter = max(p.PurgeAfter, p.RestoreWindow)
+	s.retention = p
+}
+
+// DeletePhoto soft-deletes a photo. Only its sender may delete it.
+func (s *PhotoService) DeletePhoto(ctx context.Context, photoID, userID uuid.UUID) error {
+	photo, err := s.queries.GetPhotoByID(ctx, photoID)
+	if err != nil {
+		return s.photoLookupError(ctx, photoID, err, "get photo")
+	}
+	if photo.SenderID != userID {
+		return fmt.Errorf("%w: only the sender can delete a photo", ErrForbidden)
+	}
+
+	if _, err := s.queries.SoftDeletePhoto(ctx, photoID); err != nil {
+		return mapDBError(err, "photo", "delete photo")
+	}
+	return nil
+}
+
+// RestorePhoto undoes the deletion of a photo within the restore window.
+// Only its sender may restore it, and expired photos cannot be restored.
+func (s *PhotoService) RestorePhoto(ctx context.Context, photoID, userID uuid.UUID) (*PhotoResponse, error) {
+	var photo db.Photo
+	err := s.withTx(ctx, func(q *db.Queries) error {
+		deleted, err := q.GetDeletedPhotoForUpdate(ctx, db.GetDeletedPhotoForUpdateParams{
+			RestoreWindowSeconds: int32(s.retention.RestoreWindow.Seconds()),
+			ID:                   photoID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &NotFoundError{Resource: "deleted photo"}
+		}
+		if err != nil {
+			return mapDBError(err, "photo", "get deleted photo")
+		}
+
+		switch {
+		case deleted.SenderID != userID:
+			return fmt.Errorf("%w: only the sender can restore a photo", ErrForbidden)
+		case deleted.Expired:
+			return fmt.Errorf("%w: photo has expired", ErrExpired)
+		case !deleted.Restorable:
+			return fmt.Errorf("%w: the restore window has passed", ErrExpired)
+		}
+
+		photo, err = q.RestorePhoto(ctx, photoID)
+		if err != nil {
+			return mapDBError(err, "photo", "restore photo")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := s.attachReactions(ctx, []db.Photo{photo})
+	if err != nil {
+		return nil, err
+	}
+	return &responses[0], nil
+}
+
+// PurgeDeletedPhotos permanently removes photos deleted longer ago than the
+// retention policy allows, together with their stored objects and thumbnails.
+// It returns the number of photos purged.
+func (s *PhotoService) PurgeDeletedPhotos(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		rows, err := s.queries.PurgeDeletedPhotos(ctx, db.PurgeDeletedPhotosParams{
+			RetentionSeconds: int32(s.retention.PurgeAfter.Seconds()),
+			BatchSize:        purgeBatchSize,
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to purge photos: %w", err)
+		}
+		for _, row := range rows {
+			if row.Key != nil {
+				s.deleteObjects(ctx, *row.Key)
+			}
+		}
+		total += len(rows)
+		if len(rows) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunPurgeJob purges deleted photos every interval until ctx is canceled
+func (s *PhotoService) RunPurgeJob(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		n, err := s.PurgeDeletedPhotos(ctx)
+		if err != nil {
+			log.Printf("purge job: %v", err)
+		}
+		if n > 0 {
+			log.Printf("purge job: purged %d deleted photos", n)
+		}
+	})
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// RecipientResponse represents the delivery state of a photo for one recipient
+type RecipientResponse struct {
+	PhotoID     uuid.UUID  `json:"photo_id"`
+	RecipientID uuid.UUID  `json:"recipient_id"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	ViewedAt    *time.Time `json:"viewed_at,omitempty"`
+	ViewCount   int32      `json:"view_count"`
+	Hidden      bool       `json:"hidden"`
+}
+
+// GetInbox returns one page of the photos sent to userID, newest first, with their reactions
+// in the given view. Photos the user has hidden are left out.
+func (s *PhotoService) GetInbox(ctx context.Context, userID uuid.UUID, cursor string, limit int32, view ReactionView) (*PhotoPage, error) {
+	ks, err := parseKeyset(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = clampPageSize(limit)
+	photos, err := s.queries.ListInboxPhotos(ctx, db.ListInboxPhotosParams{
+		RecipientID:     userID,
+		HasCursor:       ks.HasCursor,
+		CursorCreatedAt: ks.CreatedAt,
+		CursorID:        ks.ID,
+		PageSize:        limit + 1, // one extra row tells us whether there is a next page
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inbox: %w", err)
+	}
+
+	photos, nextCursor := paginate(photos, limit)
+	responses, err := s.withReactions(ctx, userID, photos, view)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PhotoPage{Photos: responses, NextCursor: nextCursor}, nil
+}
+
+// GetFeed returns one page of userID's feed, newest first, with their reactions in the
+// given view. The feed merges the photos sent to userID with those their friends shared
+// with all friends, in a single query; hidden photos are left out.
+func (s *PhotoService) GetFeed(ctx context.Context, userID uuid.UUID, cursor string, limit int32, view ReactionView) (*PhotoPage, error) {
+	ks, err := parseKeyset(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = clampPageSize(limit)
+	photos, err := s.queries.ListFeedPhotos(ctx, db.ListFeedPhotosParams{
+		ViewerID:        userID,
+		HasCursor:       ks.HasCursor,
+		CursorCreatedAt: ks.CreatedAt,
+		CursorID:        ks.ID,
+		PageSize:        limit + 1, // one extra row tells us whether there is a next page
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list feed: %w", err)
+	}
+
+	photos, nextCursor := paginate(photos, limit)
+	responses, err := s.withReactions(ctx, userID, photos, view)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PhotoPage{Photos: responses, NextCursor: nextCursor}, nil
+}
+
+// MarkPhotoViewed records that userID viewed a photo sent to them
+func (s *PhotoService) MarkPhotoViewed(ctx context.Context, photoID, userID uuid.UUID) (*RecipientResponse, error) {
+	r, err := s.queries.MarkPhotoViewed(ctx, db.MarkPhotoViewedParams{PhotoID: photoID, RecipientID: userID})
+	if err != nil {
+		return nil, mapDBError(err, "photo", "mark photo viewed")
+	}
+	return newRecipientResponse(r), nil
+}
+
+// HidePhoto removes a photo sent to userID from their inbox
+func (s *PhotoService) HidePhoto(ctx context.Context, photoID, userID uuid.UUID) (*RecipientResponse, error) {
+	r, err := s.queries.HidePhotoForRecipient(ctx, db.HidePhotoForRecipientParams{PhotoID: photoID, RecipientID: userID})
+	if err != nil {
+		return nil, mapDBError(err, "photo", "hide photo")
+	}
+	return newRecipientResponse(r), nil
+}
+
+// newRecipientResponse converts a photo_recipients row to its API response
+func newRecipientResponse(r db.PhotoRecipient) *RecipientResponse {
+	return &RecipientResponse{
+		PhotoID:     r.PhotoID,
+		RecipientID: r.RecipientID,
+		DeliveredAt: r.DeliveredAt,
+		ViewedAt:    r.ViewedAt,
+		ViewCount:   r.ViewCount,
+		Hidden:      r.Hidden,
+	}
+}
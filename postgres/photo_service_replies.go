@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// MaxReplyLength is the longest reply accepted, in characters, matching photo_replies_body_check
+const MaxReplyLength = 1000
+
+// ReplyResponse represents a text reply to a photo in the API response
+type ReplyResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	PhotoID      uuid.UUID  `json:"photo_id"`
+	ThreadUserID uuid.UUID  `json:"thread_user_id"` // the friend the thread is with
+	AuthorID     uuid.UUID  `json:"author_id"`
+	Body         string     `json:"body"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EditedAt     *time.Time `json:"edited_at,omitempty"`
+}
+
+// ReplyPage is one page of replies with the cursor for the next page
+type ReplyPage struct {
+	Replies    []ReplyResponse `json:"replies"`
+	NextCursor *string         `json:"next_cursor"` // null on the last page
+}
+
+// CreateReply adds a reply to a photo. Each friend who can see the photo has a private
+// thread with its sender. Friends always reply in their own thread; the sender
+// must choose the thread with threadUserID, who has to be able to see the photo.
+func (s *PhotoService) CreateReply(ctx context.Context, photoID, authorID, threadUserID uuid.UUID, body string) (*ReplyResponse, error) {
+	body, err := checkReplyBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	photo, err := s.queries.GetPhotoByID(ctx, photoID)
+	if err != nil {
+		return nil, s.photoLookupError(ctx, photoID, err, "get photo")
+	}
+
+	if authorID == photo.SenderID {
+		if threadUserID == uuid.Nil || threadUserID == authorID {
+			return nil, &ValidationError{Field: "thread_user_id", Message: "choose the friend to reply to"}
+		}
+		canView, err := s.queries.CanViewPhoto(ctx, db.CanViewPhotoParams{ViewerID: threadUserID, PhotoID: photoID})
+		if err != nil {
+			return nil, s.photoLookupError(ctx, photoID, err, "check photo visibility")
+		}
+		if !canView {
+			return nil, &ValidationError{Field: "thread_user_id", Message: "this user cannot see the photo"}
+		}
+	} else {
+		if err := s.checkPhotoVisible(ctx, authorID, photoID); err != nil {
+			return nil, err
+		}
+		if threadUserID != uuid.Nil && threadUserID != authorID {
+			return nil, fmt.Errorf("%w: you can only reply in your own thread", ErrForbidden)
+		}
+		threadUserID = authorID
+	}
+
+	reply, err := s.queries.CreatePhotoReply(ctx, db.CreatePhotoReplyParams{
+		PhotoID:      photoID,
+		ThreadUserID: threadUserID,
+		AuthorID:     authorID,
+		Body:         body,
+	})
+	if err != nil {
+		return nil, mapDBError(err, "reply", "create reply")
+	}
+	return newReplyResponse(reply), nil
+}
+
+// ListReplies returns one page of the replies to a photo that viewerID can see, newest first.
+// The sender sees every thread, or only the one with threadUserID if it is set;
+// anyone else sees their own thread.
+func (s *PhotoService) ListReplies(ctx context.Context, viewerID, photoID, threadUserID uuid.UUID, cursor string, limit int32) (*ReplyPage, error) {
+	photo, err := s.queries.GetPhotoByID(ctx, photoID)
+	if err != nil {
+		return nil, s.photoLookupError(ctx, photoID, err, "get photo")
+	}
+
+	allThreads := false
+	if viewerID == photo.SenderID {
+		allThreads = threadUserID == uuid.Nil
+	} else {
+		if err := s.checkPhotoVisible(ctx, viewerID, photoID); err != nil {
+			return nil, err
+		}
+		threadUserID = viewerID
+	}
+
+	ks, err := parseKeyset(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	limit = clampPageSize(limit)
+	replies, err := s.queries.ListPhotoReplies(ctx, db.ListPhotoRepliesParams{
+		PhotoID:         photoID,
+		AllThreads:      allThreads,
+		ThreadUserID:    threadUserID,
+		HasCursor:       ks.HasCursor,
+		CursorCreatedAt: ks.CreatedAt,
+		CursorID:        ks.ID,
+		PageSize:        limit + 1, // one extra row tells us whether there is a next page
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replies: %w", err)
+	}
+
+	var nextCursor *string
+	if len(replies) > int(limit) {
+		replies = replies[:limit]
+		last := replies[len(replies)-1]
+		c := encodeCursor(last.CreatedAt, last.ID)
+		nextCursor = &c
+	}
+
+	responses := make([]ReplyResponse, 0, len(replies))
+	for _, r := range replies {
+		responses = append(responses, *newReplyResponse(r))
+	}
+	return &ReplyPage{Replies: responses, NextCursor: nextCursor}, nil
+}
+
+// UpdateReply edits the text of a reply. Only its author may edit it.
+func (s *PhotoService) UpdateReply(ctx context.Context, photoID, replyID, userID uuid.UUID, body string) (*ReplyResponse, error) {
+	body, err := checkReplyBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.getReply(ctx, photoID, replyID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if reply.AuthorID != userID {
+		return nil, fmt.Errorf("%w: only the author can edit a reply", ErrForbidden)
+	}
+
+	updated, err := s.queries.UpdatePhotoReply(ctx, db.UpdatePhotoReplyParams{ID: replyID, Body: body})
+	if err != nil {
+		return nil, mapDBError(err, "reply", "update reply")
+	}
+	return newReplyResponse(updated), nil
+}
+
+// DeleteReply deletes a reply. Its author and the photo's sender may delete it.
+func (s *PhotoService) DeleteReply(ctx context.Context, photoID, replyID, userID uuid.UUID) error {
+	reply, err := s.getReply(ctx, photoID, replyID, userID)
+	if err != nil {
+		return err
+	}
+	if reply.AuthorID != userID && reply.PhotoSenderID != userID {
+		return fmt.Errorf("%w: only the author or the photo's sender can delete a reply", ErrForbidden)
+	}
+
+	if err := s.queries.DeletePhotoReply(ctx, replyID); err != nil {
+		return mapDBError(err, "reply", "delete reply")
+	}
+	return nil
+}
+
+// getReply fetches a reply to a photo. Replies in threads userID is not part of
+// are reported as not found.
+func (s *PhotoService) getReply(ctx context.Context, photoID, replyID, userID uuid.UUID) (db.GetPhotoReplyRow, error) {
+	reply, err := s.queries.GetPhotoReply(ctx, db.GetPhotoReplyParams{ID: replyID, PhotoID: photoID})
+	if err != nil {
+		return reply, mapDBError(err, "reply", "get reply")
+	}
+	if userID != reply.PhotoSenderID && userID != reply.ThreadUserID {
+		return reply, &NotFoundError{Resource: "reply"}
+	}
+	return reply, nil
+}
+
+// attachReplyCounts sets ReplyCount on each photo to the number of replies viewerID can see
+func (s *PhotoService) attachReplyCounts(ctx context.Context, viewerID uuid.UUID, photos []PhotoResponse) error {
+	if len(photos) == 0 {
+		return nil
+	}
+
+	photoIDs := make([]uuid.UUID, 0, len(photos))
+	for _, p := range photos {
+		photoIDs = append(photoIDs, p.ID)
+	}
+
+	counts, err := s.queries.CountRepliesByPhotoIDs(ctx, db.CountRepliesByPhotoIDsParams{
+		PhotoIds: photoIDs,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to count replies: %w", err)
+	}
+
+	byPhoto := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		byPhoto[c.PhotoID] = c.ReplyCount
+	}
+	for i := range photos {
+		count := byPhoto[photos[i].ID]
+		photos[i].ReplyCount = &count
+	}
+	return nil
+}
+
+// withReplyCount sets ReplyCount on a single photo
+func (s *PhotoService) withReplyCount(ctx context.Context, viewerID uuid.UUID, photo *PhotoResponse) (*PhotoResponse, error) {
+	photos := []PhotoResponse{*photo}
+	if err := s.attachReplyCounts(ctx, viewerID, photos); err != nil {
+		return nil, err
+	}
+	return &photos[0], nil
+}
+
+// checkReplyBody trims a reply and checks that it is not empty or too long
+func checkReplyBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", &ValidationError{Field: "body", Message: "reply cannot be empty"}
+	}
+	if utf8.RuneCountInString(body) > MaxReplyLength {
+		return "", &ValidationError{Field: "body", Message: "reply must be at most 1000 characters"}
+	}
+	return body, nil
+}
+
+// newReplyResponse converts a photo_replies row to its API response
+func newReplyResponse(r db.PhotoReply) *ReplyResponse {
+	return &ReplyResponse{
+		ID:           r.ID,
+		PhotoID:      r.PhotoID,
+		ThreadUserID: r.ThreadUserID,
+		AuthorID:     r.AuthorID,
+		Body:         r.Body,
+		CreatedAt:    r.CreatedAt,
+		EditedAt:     r.EditedAt,
+	}
+}
@@ -2,10 +2,8 @@ package service
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/yourusername/yourproject/db" // Update with your actual path
 )
 
 // SimpleReactionResponse represents a minimal reaction in the API response
@@ -21,49 +19,40 @@ type SimplePhotoResponse struct {
 	Reactions []SimpleReactionResponse `json:"reactions"` // Always include, empty if no reactions
 }
 
-// GetPhotosWithReactionsSimple fetches photos with only essential data (id, photo_url, reaction id, emoji)
+// SimplePhotoPage is one page of minimal photos with the cursor for the next page
+type SimplePhotoPage struct {
+	Photos     []SimplePhotoResponse `json:"photos"`
+	NextCursor *string               `json:"next_cursor"` // null on the last page
+}
+
+// GetPhotosWithReactionsSimple fetches one page of photos visible to viewerID with only essential data (id, photo_url, reaction id, emoji)
 // This is optimized for lightweight API responses
-func (s *PhotoService) GetPhotosWithReactionsSimple(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]SimplePhotoResponse, error) {
-	rows, err := s.queries.GetPhotosWithReactionsSimple(ctx, db.GetPhotosWithReactionsSimpleParams{
-		SenderID: userID,
-		Limit:    limit,
-		Offset:   offset,
-	})
+func (s *PhotoService) GetPhotosWithReactionsSimple(ctx context.Context, viewerID, userID uuid.UUID, cursor string, limit int32) (*SimplePhotoPage, error) {
+	photos, nextCursor, err := s.listPhotosByUser(ctx, viewerID, userID, cursor, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get photos with reactions: %w", err)
+		return nil, err
 	}
 
-	// Group by photo ID
-	photoMap := make(map[uuid.UUID]*SimplePhotoResponse)
-	var photoOrder []uuid.UUID // Track order
-
-	for _, row := range rows {
-		photoID := row.PhotoID
+	full, err := s.attachReactions(ctx, photos)
+	if err != nil {
+		return nil, err
+	}
 
-		// Create photo response if not exists
-		if _, exists := photoMap[photoID]; !exists {
-			photoMap[photoID] = &SimplePhotoResponse{
-				ID:        row.PhotoID,
-				PhotoURL:  row.PhotoURL,
-				Reactions: make([]SimpleReactionResponse, 0),
-			}
-			photoOrder = append(photoOrder, photoID)
+	result := make([]SimplePhotoResponse, 0, len(full))
+	for _, p := range full {
+		simple := SimplePhotoResponse{
+			ID:        p.ID,
+			PhotoURL:  p.PhotoURL,
+			Reactions: make([]SimpleReactionResponse, 0, len(p.Reactions)),
 		}
-
-		// Add reaction if exists (LEFT JOIN might return NULL)
-		if row.ReactionID != nil {
-			photoMap[photoID].Reactions = append(photoMap[photoID].Reactions, SimpleReactionResponse{
-				ID:    *row.ReactionID,
-				Emoji: *row.ReactionEmoji,
+		for _, r := range p.Reactions {
+			simple.Reactions = append(simple.Reactions, SimpleReactionResponse{
+				ID:    r.ID,
+				Emoji: r.Emoji,
 			})
 		}
+		result = append(result, simple)
 	}
 
-	// Convert map to ordered slice
-	result := make([]SimplePhotoResponse, 0, len(photoOrder))
-	for _, photoID := range photoOrder {
-		result = append(result, *photoMap[photoID])
-	}
-
-	return result, nil
+	return &SimplePhotoPage{Photos: result, NextCursor: nextCursor}, nil
 }
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// EmojiCount is the number of reactions to a photo with one emoji
+type EmojiCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"` // the viewer reacted with this emoji
+}
+
+// ReactionSummary aggregates the reactions to a photo
+type ReactionSummary struct {
+	Total         int64        `json:"total"`
+	Emojis        []EmojiCount `json:"emojis"` // most used first
+	ViewerReacted bool         `json:"viewer_reacted"`
+}
+
+// ReactionView selects how photo lists return reactions
+type ReactionView int
+
+const (
+	// ReactionViewFull lists every reaction in PhotoResponse.Reactions
+	ReactionViewFull ReactionView = iota
+	// ReactionViewSummary only returns counters in PhotoResponse.ReactionSummary
+	ReactionViewSummary
+)
+
+// GetReactionSummary returns the reaction counters of a photo visible to viewerID
+func (s *PhotoService) GetReactionSummary(ctx context.Context, viewerID, photoID uuid.UUID) (*ReactionSummary, error) {
+	if err := s.checkPhotoVisible(ctx, viewerID, photoID); err != nil {
+		return nil, err
+	}
+
+	total, err := s.queries.GetReactionCount(ctx, photoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+
+	counts, err := s.queries.GetReactionCountByEmoji(ctx, photoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reactions by emoji: %w", err)
+	}
+
+	mine, err := s.queries.GetUserReactionEmojis(ctx, db.GetUserReactionEmojisParams{PhotoID: photoID, UserID: viewerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get viewer reactions: %w", err)
+	}
+	reacted := make(map[string]bool, len(mine))
+	for _, emoji := range mine {
+		reacted[emoji] = true
+	}
+
+	summary := &ReactionSummary{
+		Total:         total,
+		Emojis:        make([]EmojiCount, 0, len(counts)),
+		ViewerReacted: len(mine) > 0,
+	}
+	for _, c := range counts {
+		summary.Emojis = append(summary.Emojis, EmojiCount{Emoji: c.Emoji, Count: c.Count, Reacted: reacted[c.Emoji]})
+	}
+	return summary, nil
+}
+
+// withReactions converts a page of photos to API responses with their reactions
+// in the requested view and the number of replies viewerID can see
+func (s *PhotoService) withReactions(ctx context.Context, viewerID uuid.UUID, photos []db.Photo, view ReactionView) ([]PhotoResponse, error) {
+	var responses []PhotoResponse
+	var err error
+	if view == ReactionViewSummary {
+		responses, err = s.attachReactionSummaries(ctx, viewerID, photos)
+	} else {
+		responses, err = s.attachReactions(ctx, photos)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachReplyCounts(ctx, viewerID, responses); err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// attachReactionSummaries loads the reaction counters of all photos with one query.
+// Reactions is left nil, so the full list is not sent.
+func (s *PhotoService) attachReactionSummaries(ctx context.Context, viewerID uuid.UUID, photos []db.Photo) ([]PhotoResponse, error) {
+	result := make([]PhotoResponse, 0, len(photos))
+	if len(photos) == 0 {
+		return result, nil
+	}
+
+	photoIDs := make([]uuid.UUID, 0, len(photos))
+	for _, p := range photos {
+		photoIDs = append(photoIDs, p.ID)
+	}
+
+	counts, err := s.queries.GetReactionSummariesByPhotoIDs(ctx, db.GetReactionSummariesByPhotoIDsParams{
+		ViewerID: viewerID,
+		PhotoIds: photoIDs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction summaries: %w", err)
+	}
+
+	// Group by photo ID; rows are ordered by count within each photo
+	summaries := make(map[uuid.UUID]*ReactionSummary, len(photos))
+	for _, c := range counts {
+		summary, ok := summaries[c.PhotoID]
+		if !ok {
+			summary = &ReactionSummary{Emojis: make([]EmojiCount, 0)}
+			summaries[c.PhotoID] = summary
+		}
+		summary.Total += c.Count
+		summary.Emojis = append(summary.Emojis, EmojiCount{Emoji: c.Emoji, Count: c.Count, Reacted: c.ViewerReacted})
+		summary.ViewerReacted = summary.ViewerReacted || c.ViewerReacted
+	}
+
+	for _, p := range photos {
+		response := newPhotoResponse(p)
+		response.Reactions = nil
+		response.ReactionSummary = summaries[p.ID]
+		if response.ReactionSummary == nil {
+			response.ReactionSummary = &ReactionSummary{Emojis: make([]EmojiCount, 0)}
+		}
+		result = append(result, response)
+	}
+	if err := s.signPhotoURLs(ctx, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
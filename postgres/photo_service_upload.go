@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// DefaultUploadTTL is how long clients have to finish a direct upload
+const DefaultUploadTTL = time.Hour
+
+// uploadGracePeriod lets transfers started just before an upload expired finish
+// before the upload collector removes their object
+const uploadGracePeriod = 10 * time.Minute
+
+// uploadBatchSize is the number of abandoned uploads collected per query
+const uploadBatchSize = 100
+
+// UploadSigner issues short-lived URLs a client uploads an object to with a PUT request
+type UploadSigner interface {
+	// SignUploadURL returns a URL accepting a PUT of the object stored under key until ttl has passed
+	SignUploadURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// CreateUploadParams declares the photo a client is about to upload directly
+type CreateUploadParams struct {
+	SenderID uuid.UUID
+	MimeType string
+	FileSize int64
+	SHA256   string // hex-encoded digest of the photo bytes
+}
+
+// UploadResponse tells the client where to upload a photo
+type UploadResponse struct {
+	ID        uuid.UUID         `json:"id"`  // also the ID of the photo once completed
+	Key       string            `json:"key"` // staging key, not the photo's
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`  // always PUT
+	Headers   map[string]string `json:"headers"` // headers to send with the upload
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// CompleteUploadParams holds the photo details given when completing a direct upload
+type CompleteUploadParams struct {
+	Caption   *string
+	ExpiresAt *time.Time
+	// RecipientIDs are the friends the photo is sent to.
+	// An empty list sends the photo to all of the sender's current friends.
+	RecipientIDs []uuid.UUID
+}
+
+// uploadKey returns the staging key a direct upload is PUT to. Photos are only
+// written under their own key once the upload is verified, so a signed upload URL
+// can never replace a stored photo.
+func uploadKey(uploadID uuid.UUID) string {
+	return "uploads/" + uploadID.String()
+}
+
+// SetUploadSigner enables direct uploads to URLs issued by signer, valid for ttl
+func (s *PhotoService) SetUploadSigner(signer UploadSigner, ttl time.Duration) {
+	s.uploads = signer
+	s.uploadTTL = ttl
+}
+
+// CreateUpload starts a direct upload: the client PUTs the photo to the returned
+// URL and then calls CompleteUpload, so large files never pass through the API.
+func (s *PhotoService) CreateUpload(ctx context.Context, params CreateUploadParams) (*UploadResponse, error) {
+	if s.uploads == nil {
+		return nil, fmt.Errorf("direct uploads are not configured")
+	}
+
+	if !IsSupportedPhotoType(params.MimeType) {
+		return nil, &ValidationError{Field: "mime_type", Message: fmt.Sprintf("unsupported mime type %q", params.MimeType)}
+	}
+	if params.FileSize <= 0 || params.FileSize > MaxPhotoSize {
+		return nil, &ValidationError{Field: "file_size", Message: fmt.Sprintf("file_size must be between 1 and %d bytes", MaxPhotoSize)}
+	}
+	digest := strings.ToLower(params.SHA256)
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return nil, &ValidationError{Field: "sha256", Message: "sha256 must be a hex-encoded SHA-256 digest"}
+	}
+
+	uploadID := uuid.New()
+	upload, err := s.queries.CreatePhotoUpload(ctx, db.CreatePhotoUploadParams{
+		ID:         uploadID,
+		SenderID:   params.SenderID,
+		Key:        uploadKey(uploadID),
+		MimeType:   params.MimeType,
+		FileSize:   int32(params.FileSize),
+		Sha256:     digest,
+		TtlSeconds: int32(s.uploadTTL.Seconds()),
+	})
+	if err != nil {
+		return nil, mapDBError(err, "upload", "create upload")
+	}
+
+	uploadURL, err := s.uploads.SignUploadURL(ctx, upload.Key, s.uploadTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign upload URL: %w", err)
+	}
+
+	return &UploadResponse{
+		ID:        upload.ID,
+		Key:       upload.Key,
+		UploadURL: uploadURL,
+		Method:    "PUT",
+		Headers:   map[string]string{"Content-Type": upload.MimeType},
+		ExpiresAt: upload.ExpiresAt,
+	}, nil
+}
+
+// CompleteUpload checks that the photo of a direct upload was stored with the
+// declared size, hash and type, then creates the photo with the upload's ID from
+// the verified bytes and removes the staged object.
+func (s *PhotoService) CompleteUpload(ctx context.Context, uploadID, senderID uuid.UUID, params CompleteUploadParams) (*PhotoResponse, error) {
+	upload, err := s.queries.GetPhotoUpload(ctx, db.GetPhotoUploadParams{ID: uploadID, SenderID: senderID})
+	if err != nil {
+		return nil, mapDBError(err, "upload", "get upload")
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, fmt.Errorf("%w: upload was not completed in time", ErrExpired)
+	}
+
+	data, err := s.readUpload(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+	photo, err := inspectUpload(data, upload.MimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	key := photoKey(senderID, upload.ID, photoExtensions[upload.MimeType])
+	response, err := s.savePhoto(ctx, CreatePhotoParams{
+		SenderID:     senderID,
+		MimeType:     upload.MimeType,
+		Caption:      params.Caption,
+		ExpiresAt:    params.ExpiresAt,
+		RecipientIDs: params.RecipientIDs,
+	}, upload.ID, key, photo, &completedUpload{
+		consume: func(q *db.Queries) error {
+			n, err := q.DeletePhotoUpload(ctx, upload.ID)
+			if err != nil {
+				return fmt.Errorf("failed to complete upload: %w", err)
+			}
+			if n == 0 {
+				return &NotFoundError{Resource: "upload"}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.blobs.Delete(ctx, upload.Key); err != nil {
+		log.Printf("failed to delete staged upload %s: %v", upload.ID, err)
+	}
+	return response, nil
+}
+
+// CheckUploadTarget reports whether objects may still be PUT under key: it must be
+// the staging key of a direct upload that was neither completed nor collected
+// (NotFoundError) and has not expired (ErrExpired)
+func (s *PhotoService) CheckUploadTarget(ctx context.Context, key string) error {
+	upload, err := s.queries.GetPhotoUploadByKey(ctx, key)
+	if err != nil {
+		return mapDBError(err, "upload", "get upload")
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return fmt.Errorf("%w: upload was not completed in time", ErrExpired)
+	}
+	return nil
+}
+
+// readUpload reads the object of a direct upload and checks it against the declared size and hash
+func (s *PhotoService) readUpload(ctx context.Context, upload db.PhotoUpload) ([]byte, error) {
+	obj, info, err := s.blobs.Get(ctx, upload.Key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, fmt.Errorf("%w: the photo has not been uploaded yet", ErrConflict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer obj.Close()
+
+	if info.Size != int64(upload.FileSize) {
+		return nil, &ValidationError{Field: "file_size", Message: fmt.Sprintf("uploaded %d bytes, declared %d", info.Size, upload.FileSize)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(obj, int64(upload.FileSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	if len(data) != int(upload.FileSize) || hex.EncodeToString(sum[:]) != upload.Sha256 {
+		return nil, &ValidationError{Field: "sha256", Message: "uploaded photo does not match the declared sha256"}
+	}
+	return data, nil
+}
+
+// CollectAbandonedUploads removes direct uploads that were never completed,
+// together with their staged object and any photo objects a failed completion
+// stored. It returns the number of uploads removed.
+func (s *PhotoService) CollectAbandonedUploads(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		rows, err := s.queries.DeleteAbandonedPhotoUploads(ctx, db.DeleteAbandonedPhotoUploadsParams{
+			GraceSeconds: int32(uploadGracePeriod.Seconds()),
+			BatchSize:    uploadBatchSize,
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to collect uploads: %w", err)
+		}
+		for _, row := range rows {
+			if err := s.blobs.Delete(ctx, row.Key); err != nil {
+				log.Printf("failed to delete staged upload %s: %v", row.ID, err)
+			}
+			// A completion stores the photo and its thumbnails under the final key inside
+			// its transaction. If the transaction then fails, the upload is kept and the
+			// objects are left behind: another completion of the same upload may own them.
+			s.deleteObjects(ctx, photoKey(row.SenderID, row.ID, photoExtensions[row.MimeType]))
+		}
+		total += len(rows)
+		if len(rows) < uploadBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunUploadCollector collects abandoned uploads every interval until ctx is canceled
+func (s *PhotoService) RunUploadCollector(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		n, err := s.CollectAbandonedUploads(ctx)
+		if err != nil {
+			log.Printf("upload collector: %v", err)
+		}
+		if n > 0 {
+			log.Printf("upload collector: removed %d abandoned uploads", n)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// Widget sizes, matching widget_settings_size_check
+const (
+	WidgetSizeSmall  = "small"
+	WidgetSizeMedium = "medium"
+	WidgetSizeLarge  = "large"
+)
+
+// WidgetSettings are a user's home-screen widget preferences
+type WidgetSettings struct {
+	Size        string `json:"size"` // small, medium or large
+	ShowCaption bool   `json:"show_caption"`
+	ShowSender  bool   `json:"show_sender"`
+}
+
+// defaultWidgetSettings apply to users who never changed their widget settings
+var defaultWidgetSettings = WidgetSettings{Size: WidgetSizeSmall, ShowCaption: true, ShowSender: true}
+
+// WidgetPhoto is the compact photo shown on a home-screen widget
+type WidgetPhoto struct {
+	ID           uuid.UUID  `json:"id"`
+	ThumbnailURL string     `json:"thumbnail_url"` // sized for the widget
+	SenderID     *uuid.UUID `json:"sender_id,omitempty"`
+	SenderName   *string    `json:"sender_name,omitempty"`
+	Caption      *string    `json:"caption,omitempty"`
+	CreatedAt    *time.Time `json:"created_at"`
+}
+
+// WidgetResponse is the payload of the home-screen widget endpoint
+type WidgetResponse struct {
+	Photo    *WidgetPhoto   `json:"photo"` // null while the feed is empty
+	Settings WidgetSettings `json:"settings"`
+	// ETag identifies what the widget shows. It ignores the signature of
+	// thumbnail_url but changes with the period the URL was signed in, so that
+	// re-signing does not invalidate the client's copy and a 304 never keeps
+	// an expired URL.
+	ETag string `json:"-"`
+}
+
+// GetWidget returns the newest photo of userID's feed, shaped by their widget settings.
+// The sender and caption are left out when the settings hide them.
+func (s *PhotoService) GetWidget(ctx context.Context, userID uuid.UUID) (*WidgetResponse, error) {
+	settings, err := s.GetWidgetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &WidgetResponse{Settings: *settings}
+	photos, err := s.queries.ListFeedPhotos(ctx, db.ListFeedPhotosParams{
+		ViewerID: userID,
+		PageSize: 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get widget photo: %w", err)
+	}
+	if len(photos) == 0 {
+		return response, setWidgetETag(response, 0)
+	}
+	p := photos[0]
+
+	photo := &WidgetPhoto{
+		ID:        p.ID,
+		CreatedAt: p.CreatedAt,
+	}
+	if settings.ShowSender {
+		name, err := s.queries.GetUserDisplayName(ctx, p.SenderID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get widget photo sender: %w", err)
+		}
+		photo.SenderID = &p.SenderID
+		photo.SenderName = name
+	}
+	if settings.ShowCaption {
+		photo.Caption = p.Caption
+	}
+	response.Photo = photo
+
+	// Hash the payload with the image key in place of its URL, then sign the URL
+	key, ok := widgetImageKey(p, settings.Size)
+	if !ok {
+		photo.ThumbnailURL = p.PhotoURL
+		return response, setWidgetETag(response, 0)
+	}
+	photo.ThumbnailURL = key
+	if err := setWidgetETag(response, s.signedURLEpoch()); err != nil {
+		return nil, err
+	}
+	if photo.ThumbnailURL, err = s.objectURL(ctx, key); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// widgetImageKey picks the thumbnail variant for the widget size. Photos stored
+// without thumbnails fall back to the original; photos registered by URL have no key.
+func widgetImageKey(p db.Photo, size string) (string, bool) {
+	switch {
+	case p.Key == nil || *p.Key == "":
+		return "", false
+	case p.ThumbnailURL == nil:
+		return *p.Key, true
+	case size == WidgetSizeSmall:
+		return ThumbnailKey(*p.Key, PrimaryThumbnailVariant), true
+	default:
+		return ThumbnailKey(*p.Key, LargeWidgetThumbnailVariant), true
+	}
+}
+
+// setWidgetETag sets the ETag of a widget payload to a hash of its content and
+// the epoch its URLs were signed in
+func setWidgetETag(w *WidgetResponse, epoch int64) error {
+	body, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to encode widget: %w", err)
+	}
+	h := sha256.New()
+	h.Write(body)
+	fmt.Fprintf(h, "\x00%d", epoch)
+	w.ETag = `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	return nil
+}
+
+// GetWidgetSettings returns userID's widget settings.
+// Users who never changed them get the defaults.
+func (s *PhotoService) GetWidgetSettings(ctx context.Context, userID uuid.UUID) (*WidgetSettings, error) {
+	settings, err := s.queries.GetWidgetSettings(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		defaults := defaultWidgetSettings
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get widget settings: %w", err)
+	}
+	return newWidgetSettings(settings), nil
+}
+
+// UpdateWidgetSettings replaces userID's widget settings
+func (s *PhotoService) UpdateWidgetSettings(ctx context.Context, userID uuid.UUID, settings WidgetSettings) (*WidgetSettings, error) {
+	switch settings.Size {
+	case WidgetSizeSmall, WidgetSizeMedium, WidgetSizeLarge:
+	default:
+		return nil, &ValidationError{Field: "size", Message: "size must be small, medium or large"}
+	}
+
+	updated, err := s.queries.UpsertWidgetSettings(ctx, db.UpsertWidgetSettingsParams{
+		UserID:      userID,
+		Size:        settings.Size,
+		ShowCaption: settings.ShowCaption,
+		ShowSender:  settings.ShowSender,
+	})
+	if err != nil {
+		return nil, mapDBError(err, "user", "update widget settings")
+	}
+	return newWidgetSettings(updated), nil
+}
+
+// newWidgetSettings converts a widget_settings row to its API response
+func newWidgetSettings(s db.WidgetSetting) *WidgetSettings {
+	return &WidgetSettings{
+		Size:        s.Size,
+		ShowCaption: s.ShowCaption,
+		ShowSender:  s.ShowSender,
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Device platforms accepted in device_tokens.platform
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWeb     = "web"
+)
+
+// ErrInvalidToken is returned by a Notifier when the provider rejects a device token
+// as unknown or expired. Such tokens are removed from the registry.
+var ErrInvalidToken = errors.New("invalid device token")
+
+// PushMessage is a push notification to one device
+type PushMessage struct {
+	Title string
+	Body  string
+	// Data is passed to the app with the notification
+	Data map[string]string
+	// CollapseKey lets the provider replace an earlier notification with the same key
+	CollapseKey string
+}
+
+// Notifier sends push notifications through one provider
+type Notifier interface {
+	Send(ctx context.Context, token string, msg PushMessage) error
+}
+
+// SentPush is a notification recorded by FakeNotifier
+type SentPush struct {
+	Token   string
+	Message PushMessage
+}
+
+// FakeNotifier records notifications instead of sending them, for tests and local development
+type FakeNotifier struct {
+	mu   sync.Mutex
+	sent []SentPush
+	// InvalidTokens are rejected with ErrInvalidToken
+	InvalidTokens map[string]bool
+	// Errors are returned for their tokens, as if the provider failed
+	Errors map[string]error
+}
+
+func (f *FakeNotifier) Send(_ context.Context, token string, msg PushMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.InvalidTokens[token] {
+		return ErrInvalidToken
+	}
+	if err := f.Errors[token]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, SentPush{Token: token, Message: msg})
+	return nil
+}
+
+// Sent returns the notifications sent so far
+func (f *FakeNotifier) Sent() []SentPush {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SentPush(nil), f.sent...)
+}
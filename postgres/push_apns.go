@@ -0,0 +1,160 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+	// apnsTokenLifetime must stay under the one hour APNs accepts a provider token for
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsConfig configures token-based authentication with Apple Push Notification service
+type APNsConfig struct {
+	KeyID      string            // ID of the .p8 signing key
+	TeamID     string            // Apple developer team ID
+	PrivateKey *ecdsa.PrivateKey // the .p8 signing key
+	Topic      string            // the app's bundle ID
+	Sandbox    bool              // use the development environment
+}
+
+// APNsNotifier sends notifications to iOS devices over the APNs HTTP/2 API
+type APNsNotifier struct {
+	cfg     APNsConfig
+	baseURL string
+	client  *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenIssued time.Time
+}
+
+// NewAPNsNotifier creates an APNs notifier
+func NewAPNsNotifier(cfg APNsConfig) *APNsNotifier {
+	baseURL := apnsProductionURL
+	if cfg.Sandbox {
+		baseURL = apnsSandboxURL
+	}
+	// The cloned default transport negotiates HTTP/2, which APNs requires
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	return &APNsNotifier{
+		cfg:     cfg,
+		baseURL: baseURL,
+		client:  &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}
+}
+
+// apnsAPS is the "aps" dictionary of an APNs payload
+type apnsAPS struct {
+	Alert struct {
+		Title string `json:"title,omitempty"`
+		Body  string `json:"body"`
+	} `json:"alert"`
+	Sound    string `json:"sound,omitempty"`
+	ThreadID string `json:"thread-id,omitempty"`
+}
+
+func (n *APNsNotifier) Send(ctx context.Context, token string, msg PushMessage) error {
+	var aps apnsAPS
+	aps.Alert.Title = msg.Title
+	aps.Alert.Body = msg.Body
+	aps.Sound = "default"
+	aps.ThreadID = msg.CollapseKey
+
+	// Custom data sits next to "aps" at the top level of the payload
+	payload := map[string]interface{}{"aps": aps}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	providerToken, err := n.providerToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.baseURL+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", n.cfg.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if msg.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", msg.CollapseKey)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("apns: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apnsErr)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		apnsErr.Reason == "BadDeviceToken",
+		apnsErr.Reason == "Unregistered",
+		apnsErr.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("apns: %s: %w", apnsErr.Reason, ErrInvalidToken)
+	case apnsErr.Reason == "ExpiredProviderToken":
+		n.resetProviderToken()
+	}
+	return fmt.Errorf("apns: %s: %s", resp.Status, apnsErr.Reason)
+}
+
+// providerToken returns the cached ES256 provider token, signing a new one when it is due
+func (n *APNsNotifier) providerToken() (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.token != "" && time.Since(n.tokenIssued) < apnsTokenLifetime {
+		return n.token, nil
+	}
+
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": n.cfg.TeamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = n.cfg.KeyID
+	signed, err := t.SignedString(n.cfg.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("apns: failed to sign provider token: %w", err)
+	}
+
+	n.token, n.tokenIssued = signed, now
+	return signed, nil
+}
+
+// resetProviderToken forces a new provider token on the next request
+func (n *APNsNotifier) resetProviderToken() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.token = ""
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmDefaultTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// fcmServiceAccount holds the fields of a Google service account key file used by FCM
+type fcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMNotifier sends notifications to Android and web clients over the FCM HTTP v1 API
+type FCMNotifier struct {
+	account fcmServiceAccount
+	key     *rsa.PrivateKey
+	client  *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMNotifier creates an FCM notifier from a service account key file (JSON)
+func NewFCMNotifier(serviceAccountJSON []byte) (*FCMNotifier, error) {
+	var account fcmServiceAccount
+	if err := json.Unmarshal(serviceAccountJSON, &account); err != nil {
+		return nil, fmt.Errorf("fcm: invalid service account: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, errors.New("fcm: service account needs project_id, client_email and private_key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = fcmDefaultTokenURI
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("fcm: invalid private key: %w", err)
+	}
+
+	// The cloned default transport negotiates HTTP/2 with the FCM endpoint
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	return &FCMNotifier{
+		account: account,
+		key:     key,
+		client:  &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}, nil
+}
+
+// fcmRequest is the JSON body of an FCM v1 send request
+type fcmRequest struct {
+	Message fcmMessage `json:"message"`
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	CollapseKey string `json:"collapse_key,omitempty"`
+}
+
+// fcmError is the error body returned by FCM
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (n *FCMNotifier) Send(ctx context.Context, token string, msg PushMessage) error {
+	message := fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:         msg.Data,
+	}
+	if msg.CollapseKey != "" {
+		message.Android = &fcmAndroid{CollapseKey: msg.CollapseKey}
+	}
+	body, err := json.Marshal(fcmRequest{Message: message})
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := n.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := "https://fcm.googleapis.com/v1/projects/" + url.PathEscape(n.account.ProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fcm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var fcmErr fcmError
+	json.NewDecoder(resp.Body).Decode(&fcmErr)
+	for _, d := range fcmErr.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" {
+			return fmt.Errorf("fcm: %s: %w", d.ErrorCode, ErrInvalidToken)
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("fcm: %s: %w", fcmErr.Error.Message, ErrInvalidToken)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		n.resetToken()
+	}
+	return fmt.Errorf("fcm: %s: %s", resp.Status, fcmErr.Error.Message)
+}
+
+// token returns a cached OAuth2 access token, exchanging a signed service account
+// assertion for a new one when it is about to expire
+func (n *FCMNotifier) token(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.accessToken != "" && time.Until(n.expiresAt) > time.Minute {
+		return n.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   n.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   n.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(n.key)
+	if err != nil {
+		return "", fmt.Errorf("fcm: failed to sign assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm: failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm: failed to get access token: %s", resp.Status)
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("fcm: invalid token response: %w", err)
+	}
+
+	n.accessToken = tok.AccessToken
+	n.expiresAt = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return n.accessToken, nil
+}
+
+// resetToken forces a new access token on the next request
+func (n *FCMNotifier) resetToken() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.accessToken = ""
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// PushSink is an OutboxSink that sends push notifications for new reactions
+// and photos to the devices of the users they concern
+type PushSink struct {
+	queries   pushTargetStore
+	notifiers map[string]Notifier
+}
+
+// pushTargetStore is the part of db.Queries used by PushSink
+type pushTargetStore interface {
+	ListPushTargets(ctx context.Context, arg db.ListPushTargetsParams) ([]db.ListPushTargetsRow, error)
+	DeleteDeviceTokens(ctx context.Context, tokens []string) error
+}
+
+// NewPushSink creates a push sink without notifiers; add them with SetNotifier
+func NewPushSink(queries *db.Queries) *PushSink {
+	return &PushSink{
+		queries:   queries,
+		notifiers: make(map[string]Notifier),
+	}
+}
+
+// SetNotifier sends the notifications of devices on platform through n
+func (s *PushSink) SetNotifier(platform string, n Notifier) {
+	s.notifiers[platform] = n
+}
+
+func (s *PushSink) Name() string { return "push" }
+
+// Deliver notifies the sender of a photo about reactions from others, and the
+// recipients of a new photo. Tokens the provider rejects are removed. It only
+// fails, so that the event is retried, when no notification could be sent.
+func (s *PushSink) Deliver(ctx context.Context, msg OutboxMessage) error {
+	e, err := msg.Event()
+	if err != nil {
+		return err
+	}
+
+	var userIDs []uuid.UUID
+	var kind string
+	push := PushMessage{
+		Data: map[string]string{"type": e.Type, "photo_id": e.PhotoID.String()},
+	}
+	switch e.Type {
+	case EventReactionAdded:
+		// Repeats of a burst reaction only notify once
+		if e.ActorID == e.SenderID || e.Reaction == nil || e.Reaction.BurstCount > 1 {
+			return nil
+		}
+		userIDs, kind = []uuid.UUID{e.SenderID}, NotificationKindReaction
+		push.Title = "New reaction"
+		push.Body = fmt.Sprintf("A friend reacted %s to your photo", e.Reaction.Emoji)
+		push.CollapseKey = "reactions-" + e.PhotoID.String()
+	case EventPhotoCreated:
+		userIDs, kind = e.RecipientIDs, NotificationKindPhoto
+		push.Title = "New photo"
+		push.Body = "A friend sent you a photo"
+		push.CollapseKey = "photo-" + e.PhotoID.String()
+	default:
+		return nil
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	targets, err := s.queries.ListPushTargets(ctx, db.ListPushTargetsParams{UserIds: userIDs, Kind: kind})
+	if err != nil {
+		return fmt.Errorf("failed to list push targets: %w", err)
+	}
+
+	var invalid []string
+	var sent, failed int
+	var lastErr error
+	for _, t := range targets {
+		notifier := s.notifiers[t.Platform]
+		if notifier == nil {
+			continue
+		}
+		err := notifier.Send(ctx, t.Token, push)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ErrInvalidToken):
+			invalid = append(invalid, t.Token)
+		default:
+			failed++
+			lastErr = err
+			log.Printf("push: failed to notify user %s on %s: %v", t.UserID, t.Platform, err)
+		}
+	}
+
+	if len(invalid) > 0 {
+		if err := s.queries.DeleteDeviceTokens(ctx, invalid); err != nil {
+			log.Printf("push: failed to prune %d invalid tokens: %v", len(invalid), err)
+		} else {
+			log.Printf("push: pruned %d invalid tokens", len(invalid))
+		}
+	}
+
+	if failed > 0 && sent == 0 {
+		return lastErr
+	}
+	return nil
+}
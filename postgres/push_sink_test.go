@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// fakePushTargets serves device tokens like ListPushTargets, leaving out the
+// users who muted a kind, and records the tokens deleted
+type fakePushTargets struct {
+	devices []db.ListPushTargetsRow
+	muted   map[uuid.UUID]string // kind muted by a user
+	listed  int
+	deleted []string
+}
+
+func (f *fakePushTargets) ListPushTargets(_ context.Context, arg db.ListPushTargetsParams) ([]db.ListPushTargetsRow, error) {
+	f.listed++
+	var targets []db.ListPushTargetsRow
+	for _, d := range f.devices {
+		if slices.Contains(arg.UserIds, d.UserID) && f.muted[d.UserID] != arg.Kind {
+			targets = append(targets, d)
+		}
+	}
+	return targets, nil
+}
+
+func (f *fakePushTargets) DeleteDeviceTokens(_ context.Context, tokens []string) error {
+	f.deleted = append(f.deleted, tokens...)
+	return nil
+}
+
+func newTestPushSink(targets *fakePushTargets, notifier *FakeNotifier) *PushSink {
+	sink := &PushSink{queries: targets, notifiers: make(map[string]Notifier)}
+	sink.SetNotifier(PlatformIOS, notifier)
+	return sink
+}
+
+// outboxMessage encodes e the way recordPhotoEvent writes it to the outbox
+func outboxMessage(t *testing.T, e Event) OutboxMessage {
+	t.Helper()
+	payload, err := json.Marshal(wireEvent{Event: e, RecipientIDs: e.RecipientIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return OutboxMessage{ID: 1, Type: e.Type, PhotoID: e.PhotoID, Payload: payload, Attempt: 1}
+}
+
+func sentTokens(n *FakeNotifier) []string {
+	var tokens []string
+	for _, p := range n.Sent() {
+		tokens = append(tokens, p.Token)
+	}
+	return tokens
+}
+
+func TestPushSinkMutedKinds(t *testing.T) {
+	sender, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	targets := &fakePushTargets{
+		devices: []db.ListPushTargetsRow{
+			{UserID: sender, Platform: PlatformIOS, Token: "sender"},
+			{UserID: alice, Platform: PlatformIOS, Token: "alice"},
+			{UserID: bob, Platform: PlatformIOS, Token: "bob"},
+		},
+		muted: map[uuid.UUID]string{
+			sender: NotificationKindReaction,
+			alice:  NotificationKindPhoto,
+			bob:    NotificationKindReaction,
+		},
+	}
+	photoID := uuid.New()
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{
+			name: "photo",
+			event: Event{
+				Type:         EventPhotoCreated,
+				PhotoID:      photoID,
+				SenderID:     sender,
+				ActorID:      sender,
+				RecipientIDs: []uuid.UUID{alice, bob},
+			},
+			want: []string{"bob"},
+		},
+		{
+			name: "reaction",
+			event: Event{
+				Type:     EventReactionAdded,
+				PhotoID:  photoID,
+				SenderID: sender,
+				ActorID:  alice,
+				Reaction: &ReactionResponse{Emoji: "🔥", BurstCount: 1},
+			},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifier := &FakeNotifier{}
+			sink := newTestPushSink(targets, notifier)
+			if err := sink.Deliver(context.Background(), outboxMessage(t, tt.event)); err != nil {
+				t.Fatal(err)
+			}
+			if got := sentTokens(notifier); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushSinkSkipsSelfReactions(t *testing.T) {
+	sender, friend := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name  string
+		actor uuid.UUID
+		burst int32
+		want  []string
+	}{
+		{"own reaction", sender, 1, nil},
+		{"friend's reaction", friend, 1, []string{"sender"}},
+		{"repeated burst", friend, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := &fakePushTargets{
+				devices: []db.ListPushTargetsRow{{UserID: sender, Platform: PlatformIOS, Token: "sender"}},
+			}
+			notifier := &FakeNotifier{}
+			sink := newTestPushSink(targets, notifier)
+			msg := outboxMessage(t, Event{
+				Type:     EventReactionAdded,
+				PhotoID:  uuid.New(),
+				SenderID: sender,
+				ActorID:  tt.actor,
+				Reaction: &ReactionResponse{Emoji: "👍", BurstCount: tt.burst},
+			})
+			if err := sink.Deliver(context.Background(), msg); err != nil {
+				t.Fatal(err)
+			}
+			if got := sentTokens(notifier); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %v, want %v", got, tt.want)
+			}
+			if tt.want == nil && targets.listed > 0 {
+				t.Fatal("looked up devices for a skipped reaction")
+			}
+		})
+	}
+}
+
+// TestPushSinkReplacedReaction delivers the events AddReaction records when a user
+// switches to another emoji in ReactionModeSingle. Reacting again with the same
+// emoji records no event, so the sender is notified once per change.
+func TestPushSinkReplacedReaction(t *testing.T) {
+	sender, friend := uuid.New(), uuid.New()
+	targets := &fakePushTargets{
+		devices: []db.ListPushTargetsRow{{UserID: sender, Platform: PlatformIOS, Token: "sender"}},
+	}
+	notifier := &FakeNotifier{}
+	sink := newTestPushSink(targets, notifier)
+
+	photoID := uuid.New()
+	events := []Event{
+		{
+			Type:     EventReactionRemoved,
+			PhotoID:  photoID,
+			SenderID: sender,
+			ActorID:  friend,
+			Reaction: &ReactionResponse{Emoji: "👍", BurstCount: 1},
+		},
+		{
+			Type:     EventReactionAdded,
+			PhotoID:  photoID,
+			SenderID: sender,
+			ActorID:  friend,
+			Reaction: &ReactionResponse{Emoji: "🔥", BurstCount: 1},
+		},
+	}
+	for _, e := range events {
+		if err := sink.Deliver(context.Background(), outboxMessage(t, e)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := notifier.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(sent))
+	}
+	if want := "A friend reacted 🔥 to your photo"; sent[0].Message.Body != want {
+		t.Fatalf("notified %q, want %q", sent[0].Message.Body, want)
+	}
+}
+
+func TestPushSinkPrunesInvalidTokens(t *testing.T) {
+	sender, friend := uuid.New(), uuid.New()
+	targets := &fakePushTargets{
+		devices: []db.ListPushTargetsRow{
+			{UserID: friend, Platform: PlatformIOS, Token: "stale"},
+			{UserID: friend, Platform: PlatformIOS, Token: "current"},
+		},
+	}
+	notifier := &FakeNotifier{InvalidTokens: map[string]bool{"stale": true}}
+	sink := newTestPushSink(targets, notifier)
+
+	msg := outboxMessage(t, Event{
+		Type:         EventPhotoCreated,
+		PhotoID:      uuid.New(),
+		SenderID:     sender,
+		ActorID:      sender,
+		RecipientIDs: []uuid.UUID{friend},
+	})
+	if err := sink.Deliver(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if got := sentTokens(notifier); !slices.Equal(got, []string{"current"}) {
+		t.Fatalf("notified %v, want [current]", got)
+	}
+	if !slices.Equal(targets.deleted, []string{"stale"}) {
+		t.Fatalf("deleted %v, want [stale]", targets.deleted)
+	}
+}
+
+func TestPushSinkPartialFailure(t *testing.T) {
+	sender, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	errUnavailable := errors.New("provider unavailable")
+
+	tests := []struct {
+		name    string
+		errors  map[string]error
+		want    []string
+		wantErr bool
+	}{
+		{"some sent", map[string]error{"alice": errUnavailable}, []string{"bob"}, false},
+		{"none sent", map[string]error{"alice": errUnavailable, "bob": errUnavailable}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := &fakePushTargets{
+				devices: []db.ListPushTargetsRow{
+					{UserID: alice, Platform: PlatformIOS, Token: "alice"},
+					{UserID: bob, Platform: PlatformIOS, Token: "bob"},
+				},
+			}
+			notifier := &FakeNotifier{Errors: tt.errors}
+			sink := newTestPushSink(targets, notifier)
+
+			msg := outboxMessage(t, Event{
+				Type:         EventPhotoCreated,
+				PhotoID:      uuid.New(),
+				SenderID:     sender,
+				ActorID:      sender,
+				RecipientIDs: []uuid.UUID{alice, bob},
+			})
+			// An error makes the relay retry the event, notifying the delivered devices again
+			err := sink.Deliver(context.Background(), msg)
+			if tt.wantErr {
+				if !errors.Is(err, errUnavailable) {
+					t.Fatalf("got %v, want the provider error", err)
+				}
+			} else if err != nil {
+				t.Fatalf("got %v, want no retry", err)
+			}
+			if got := sentTokens(notifier); !slices.Equal(got, tt.want) {
+				t.Fatalf("notified %v, want %v", got, tt.want)
+			}
+			if len(targets.deleted) > 0 {
+				t.Fatalf("deleted %v after provider errors", targets.deleted)
+			}
+		})
+	}
+}
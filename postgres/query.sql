@@ -16,7 +16,8 @@ SELECT
     expires_at,
     key
 FROM photos
-WHERE id = $1 AND is_deleted = false;
+WHERE id = $1 AND is_deleted = false
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP);
 
 -- name: GetReactionsByPhotoID :many
 -- Get all reactions for a specific photo
@@ -25,65 +26,31 @@ SELECT
     photo_id,
     user_id,
     emoji,
-    created_at
+    created_at,
+    burst_count
 FROM reactions
 WHERE photo_id = $1
 ORDER BY created_at ASC;
 
--- name: GetPhotosByUserID :many
--- Get all photos by a user (without reactions)
+-- name: GetReactionsByPhotoIDs :many
+-- Get all reactions for a page of photos in one round trip
 SELECT 
     id,
-    sender_id,
-    photo_url,
-    thumbnail_url,
-    file_size,
-    width,
-    height,
-    mime_type,
-    caption,
-    is_deleted,
-    deleted_at,
+    photo_id,
+    user_id,
+    emoji,
     created_at,
-    expires_at,
-    key
-FROM photos
-WHERE sender_id = $1 AND is_deleted = false
-ORDER BY created_at DESC
-LIMIT $2 OFFSET $3;
-
--- name: GetPhotoWithReactionsOptimized :many
--- OPTIMIZED: Get photo with reactions in a single query using LEFT JOIN
--- This returns one row per reaction (or one row with nulls if no reactions)
-SELECT 
-    p.id as photo_id,
-    p.sender_id,
-    p.photo_url,
-    p.thumbnail_url,
-    p.file_size,
-    p.width,
-    p.height,
-    p.mime_type,
-    p.caption,
-    p.is_deleted,
-    p.deleted_at,
-    p.created_at as photo_created_at,
-    p.expires_at,
-    p.key,
-    r.id as reaction_id,
-    r.user_id as reaction_user_id,
-    r.emoji as reaction_emoji,
-    r.created_at as reaction_created_at
-FROM photos p
-LEFT JOIN reactions r ON p.id = r.photo_id
-WHERE p.id = $1 AND p.is_deleted = false
-ORDER BY r.created_at ASC;
+    burst_count
+FROM reactions
+WHERE photo_id = ANY(@photo_ids::uuid[])
+ORDER BY photo_id, created_at ASC;
 
--- name: GetPhotosWithReactionsByUserID :many
--- OPTIMIZED: Get all photos by user with their reactions in a single query
--- This uses LEFT JOIN to include photos even if they have no reactions
+-- name: ListPhotosByUserID :many
+-- KEYSET: Get one page of a user's photos visible to a viewer, newest first.
+-- The page is limited on photos (not photo x reaction rows) and keyed on
+-- (created_at, id); reactions are attached with GetReactionsByPhotoIDs.
 SELECT 
-    p.id as photo_id,
+    p.id,
     p.sender_id,
     p.photo_url,
     p.thumbnail_url,
@@ -94,36 +61,24 @@ SELECT
     p.caption,
     p.is_deleted,
     p.deleted_at,
-    p.created_at as photo_created_at,
+    p.created_at,
     p.expires_at,
-    p.key,
-    r.id as reaction_id,
-    r.user_id as reaction_user_id,
-    r.emoji as reaction_emoji,
-    r.created_at as reaction_created_at
+    p.key
 FROM photos p
-LEFT JOIN reactions r ON p.id = r.photo_id
-WHERE p.sender_id = $1 AND p.is_deleted = false
-ORDER BY p.created_at DESC, r.created_at ASC
-LIMIT $2 OFFSET $3;
+WHERE p.sender_id = @sender_id
+    AND p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+    -- Other users only see the photos they were sent (or sent-to-everyone photos without recipients)
+    AND (p.sender_id = @viewer_id
+        OR EXISTS (SELECT 1 FROM photo_recipients pr WHERE pr.photo_id = p.id AND pr.recipient_id = @viewer_id)
+        OR NOT EXISTS (SELECT 1 FROM photo_recipients pr WHERE pr.photo_id = p.id))
+    AND (NOT @has_cursor::bool OR (p.created_at, p.id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT @page_size;
 
--- name: GetPhotosWithReactionsSimple :many
--- SIMPLIFIED: Get photos with reactions returning only id, photo_url, reaction_id, and emoji
--- Returns minimal data for lightweight API responses
-SELECT 
-    p.id as photo_id,
-    p.photo_url,
-    r.id as reaction_id,
-    r.emoji as reaction_emoji
-FROM photos p
-LEFT JOIN reactions r ON p.id = r.photo_id
-WHERE p.sender_id = $1 AND p.is_deleted = false
-ORDER BY p.created_at DESC, r.created_at ASC
-LIMIT $2 OFFSET $3;
-
--- name: GetPhotosWithReactionsComplete :many
--- COMPLETE: Single query to get all photo fields with full reaction details
--- Returns all photo metadata with complete reaction information
+-- name: GetPhotoWithReactionsOptimized :many
+-- OPTIMIZED: Get photo with reactions in a single query using LEFT JOIN
+-- This returns one row per reaction (or one row with nulls if no reactions)
 SELECT 
     p.id as photo_id,
     p.sender_id,
@@ -140,15 +95,15 @@ SELECT
     p.expires_at,
     p.key,
     r.id as reaction_id,
-    r.photo_id as reaction_photo_id,
     r.user_id as reaction_user_id,
     r.emoji as reaction_emoji,
-    r.created_at as reaction_created_at
+    r.created_at as reaction_created_at,
+    r.burst_count as reaction_burst_count
 FROM photos p
 LEFT JOIN reactions r ON p.id = r.photo_id
-WHERE p.sender_id = $1 AND p.is_deleted = false
-ORDER BY p.created_at DESC, r.created_at ASC
-LIMIT $2 OFFSET $3;
+WHERE p.id = $1 AND p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+ORDER BY r.created_at ASC;
 
 -- name: GetPhotoWithReactionsAggregate :one
 -- ALTERNATIVE: Get photo with aggregated reaction count and emojis
@@ -174,7 +129,8 @@ SELECT
                 'id', r.id,
                 'user_id', r.user_id,
                 'emoji', r.emoji,
-                'created_at', r.created_at
+                'created_at', r.created_at,
+                'burst_count', r.burst_count
             ) ORDER BY r.created_at ASC
         ) FILTER (WHERE r.id IS NOT NULL),
         '[]'::jsonb
@@ -182,27 +138,63 @@ SELECT
 FROM photos p
 LEFT JOIN reactions r ON p.id = r.photo_id
 WHERE p.id = $1 AND p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
 GROUP BY p.id;
 
+-- name: CreatePhoto :one
+-- Create a new photo record for an uploaded object
+INSERT INTO photos (
+    id,
+    sender_id,
+    photo_url,
+    thumbnail_url,
+    file_size,
+    width,
+    height,
+    mime_type,
+    caption,
+    expires_at,
+    key
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+RETURNING id, sender_id, photo_url, thumbnail_url, file_size, width, height, mime_type, caption, is_deleted, deleted_at, created_at, expires_at, key;
+
 -- name: CreateReaction :one
--- Create a new reaction for a photo
+-- Create a new reaction for a photo. Reacting again with the same emoji keeps one
+-- reaction; with @burst its counter goes up, to at most @max_burst. Without @burst
+-- the repeat changes nothing and no row is returned.
 INSERT INTO reactions (
     photo_id,
     user_id,
     emoji
 ) VALUES (
-    $1, $2, $3
+    @photo_id, @user_id, @emoji
 )
-ON CONFLICT (photo_id, user_id) 
+ON CONFLICT (photo_id, user_id, emoji) 
 DO UPDATE SET 
-    emoji = EXCLUDED.emoji,
+    burst_count = LEAST(reactions.burst_count + 1, @max_burst::int),
     created_at = CURRENT_TIMESTAMP
-RETURNING id, photo_id, user_id, emoji, created_at;
+WHERE @burst::bool
+RETURNING id, photo_id, user_id, emoji, created_at, burst_count;
 
--- name: DeleteReaction :exec
--- Delete a reaction
+-- name: LockUserReactions :exec
+-- Serialize changes to one user's reactions to a photo until the transaction ends
+SELECT pg_advisory_xact_lock(hashtextextended(@photo_id::uuid::text || @user_id::uuid::text, 0));
+
+-- name: DeleteOtherReactions :many
+-- Delete a user's reactions to a photo with any other emoji, so that one remains
 DELETE FROM reactions
-WHERE photo_id = $1 AND user_id = $2;
+WHERE photo_id = $1 AND user_id = $2 AND emoji <> $3
+RETURNING id, photo_id, user_id, emoji, created_at, burst_count;
+
+-- name: DeleteReactions :many
+-- Delete a user's reactions to a photo, or only the one with @emoji when it is not empty,
+-- returning them so the removals can be announced
+DELETE FROM reactions
+WHERE photo_id = @photo_id AND user_id = @user_id
+    AND (@emoji::text = '' OR emoji = @emoji::text)
+RETURNING id, photo_id, user_id, emoji, created_at, burst_count;
 
 -- name: GetReactionCount :one
 -- Get total reaction count for a photo
@@ -219,3 +211,693 @@ FROM reactions
 WHERE photo_id = $1
 GROUP BY emoji
 ORDER BY count DESC;
+
+-- name: GetUserReactionEmojis :many
+-- Get the emojis a user reacted to a photo with
+SELECT emoji
+FROM reactions
+WHERE photo_id = $1 AND user_id = $2;
+
+-- name: GetUserReaction :one
+-- Get a user's reaction to a photo with one emoji
+SELECT id, photo_id, user_id, emoji, created_at, burst_count
+FROM reactions
+WHERE photo_id = $1 AND user_id = $2 AND emoji = $3;
+
+-- name: GetReactionSummariesByPhotoIDs :many
+-- Get per-emoji reaction counts for a page of photos in one round trip,
+-- with whether the viewer reacted with each emoji
+SELECT 
+    photo_id,
+    emoji,
+    COUNT(*) as count,
+    bool_or(user_id = @viewer_id)::bool as viewer_reacted
+FROM reactions
+WHERE photo_id = ANY(@photo_ids::uuid[])
+GROUP BY photo_id, emoji
+ORDER BY photo_id, count DESC, emoji;
+
+-- name: GetFriendship :one
+-- Get the relationship between two users, in either direction
+SELECT requester_id, addressee_id, status, created_at, updated_at
+FROM friendships
+WHERE (requester_id = @user_a AND addressee_id = @user_b)
+   OR (requester_id = @user_b AND addressee_id = @user_a);
+
+-- name: CreateFriendship :one
+-- Create a relationship row (a friend request or a block)
+INSERT INTO friendships (
+    requester_id,
+    addressee_id,
+    status
+) VALUES (
+    $1, $2, $3
+)
+RETURNING requester_id, addressee_id, status, created_at, updated_at;
+
+-- name: UpdateFriendshipStatus :one
+-- Move a relationship from one status to another.
+-- Returns no row if the relationship is not in the expected status.
+UPDATE friendships
+SET status = @new_status,
+    updated_at = CURRENT_TIMESTAMP
+WHERE requester_id = @requester_id
+  AND addressee_id = @addressee_id
+  AND status = @current_status
+RETURNING requester_id, addressee_id, status, created_at, updated_at;
+
+-- name: DeleteFriendship :exec
+-- Delete the relationship between two users, in either direction
+DELETE FROM friendships
+WHERE (requester_id = @user_a AND addressee_id = @user_b)
+   OR (requester_id = @user_b AND addressee_id = @user_a);
+
+-- name: AreFriends :one
+-- Check whether two users are accepted friends
+SELECT EXISTS (
+    SELECT 1
+    FROM friendships
+    WHERE status = 'accepted'
+      AND ((requester_id = @user_a AND addressee_id = @user_b)
+        OR (requester_id = @user_b AND addressee_id = @user_a))
+) AS are_friends;
+
+-- name: ListFriends :many
+-- Get all accepted friends of a user
+SELECT
+    (CASE WHEN requester_id = @user_id THEN addressee_id ELSE requester_id END)::uuid AS friend_id,
+    updated_at AS friends_since
+FROM friendships
+WHERE status = 'accepted'
+  AND (requester_id = @user_id OR addressee_id = @user_id)
+ORDER BY updated_at DESC;
+
+-- name: ListIncomingFriendRequests :many
+-- Get pending friend requests sent to a user
+SELECT requester_id, addressee_id, status, created_at, updated_at
+FROM friendships
+WHERE addressee_id = $1 AND status = 'pending'
+ORDER BY created_at DESC;
+
+-- name: CanViewPhoto :one
+-- Check whether a viewer may see a photo: the sender always can; anyone else
+-- must be an accepted friend of the sender and one of the photo's recipients
+-- (photos without recipient rows are visible to all friends)
+SELECT (
+    p.sender_id = @viewer_id
+    OR (
+        EXISTS (
+            SELECT 1
+            FROM friendships f
+            WHERE f.status = 'accepted'
+              AND ((f.requester_id = p.sender_id AND f.addressee_id = @viewer_id)
+                OR (f.requester_id = @viewer_id AND f.addressee_id = p.sender_id))
+        )
+        AND (
+            EXISTS (SELECT 1 FROM photo_recipients pr WHERE pr.photo_id = p.id AND pr.recipient_id = @viewer_id)
+            OR NOT EXISTS (SELECT 1 FROM photo_recipients pr WHERE pr.photo_id = p.id)
+        )
+    )
+)::bool AS can_view
+FROM photos p
+WHERE p.id = @photo_id AND p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP);
+
+-- name: CountFriendsAmong :one
+-- Count how many of the given users are accepted friends of a user
+SELECT COUNT(*) AS count
+FROM friendships
+WHERE status = 'accepted'
+  AND ((requester_id = @user_id AND addressee_id = ANY(@friend_ids::uuid[]))
+    OR (addressee_id = @user_id AND requester_id = ANY(@friend_ids::uuid[])));
+
+-- name: AddPhotoRecipients :exec
+-- Deliver a photo to a set of recipients
+INSERT INTO photo_recipients (photo_id, recipient_id)
+SELECT @photo_id::uuid, unnest(@recipient_ids::uuid[]);
+
+-- name: ListInboxPhotos :many
+-- KEYSET: Get one page of the photos sent to a recipient, newest first.
+-- Photos of senders who are no longer accepted friends are left out (see CanViewPhoto).
+SELECT 
+    p.id,
+    p.sender_id,
+    p.photo_url,
+    p.thumbnail_url,
+    p.file_size,
+    p.width,
+    p.height,
+    p.mime_type,
+    p.caption,
+    p.is_deleted,
+    p.deleted_at,
+    p.created_at,
+    p.expires_at,
+    p.key
+FROM photos p
+WHERE p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+    AND EXISTS (
+        SELECT 1
+        FROM photo_recipients pr
+        WHERE pr.photo_id = p.id AND pr.recipient_id = @recipient_id AND pr.hidden = false
+    )
+    AND EXISTS (
+        SELECT 1
+        FROM friendships f
+        WHERE f.status = 'accepted'
+          AND ((f.requester_id = p.sender_id AND f.addressee_id = @recipient_id)
+            OR (f.requester_id = @recipient_id AND f.addressee_id = p.sender_id))
+    )
+    AND (NOT @has_cursor::bool OR (p.created_at, p.id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+ORDER BY p.created_at DESC, p.id DESC
+LIMIT @page_size;
+
+-- name: ListFeedPhotos :many
+-- KEYSET: Get one page of the viewer's feed, newest first: photos accepted friends
+-- sent to the viewer or shared with all their friends. Hidden photos are left out.
+-- Each branch reads at most a page through its own index: the viewer's
+-- photo_recipients rows, and idx_photos_sender for every friend.
+WITH friends AS (
+    SELECT CASE WHEN f.requester_id = @viewer_id THEN f.addressee_id ELSE f.requester_id END AS friend_id
+    FROM friendships f
+    WHERE f.status = 'accepted'
+      AND (f.requester_id = @viewer_id OR f.addressee_id = @viewer_id)
+),
+feed AS (
+    (
+        SELECT p.id, p.created_at
+        FROM photo_recipients pr
+        JOIN photos p ON p.id = pr.photo_id
+        WHERE pr.recipient_id = @viewer_id
+            AND pr.hidden = false
+            AND p.is_deleted = false
+            AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+            AND p.sender_id IN (SELECT friend_id FROM friends)
+            AND (NOT @has_cursor::bool OR (p.created_at, p.id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+        ORDER BY p.created_at DESC, p.id DESC
+        LIMIT @page_size
+    )
+    UNION ALL
+    (
+        SELECT shared.id, shared.created_at
+        FROM friends
+        CROSS JOIN LATERAL (
+            SELECT p.id, p.created_at
+            FROM photos p
+            WHERE p.sender_id = friends.friend_id
+                AND p.is_deleted = false
+                AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+                AND NOT EXISTS (SELECT 1 FROM photo_recipients pr WHERE pr.photo_id = p.id)
+                AND (NOT @has_cursor::bool OR (p.created_at, p.id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+            ORDER BY p.created_at DESC, p.id DESC
+            LIMIT @page_size
+        ) shared
+        ORDER BY shared.created_at DESC, shared.id DESC
+        LIMIT @page_size
+    )
+)
+SELECT 
+    p.id,
+    p.sender_id,
+    p.photo_url,
+    p.thumbnail_url,
+    p.file_size,
+    p.width,
+    p.height,
+    p.mime_type,
+    p.caption,
+    p.is_deleted,
+    p.deleted_at,
+    p.created_at,
+    p.expires_at,
+    p.key
+FROM feed
+JOIN photos p ON p.id = feed.id
+ORDER BY feed.created_at DESC, feed.id DESC
+LIMIT @page_size;
+
+-- name: MarkPhotoViewed :one
+-- Record that a recipient viewed a photo
+UPDATE photo_recipients
+SET viewed_at = COALESCE(viewed_at, CURRENT_TIMESTAMP),
+    view_count = view_count + 1
+WHERE photo_id = $1 AND recipient_id = $2
+RETURNING photo_id, recipient_id, delivered_at, viewed_at, view_count, hidden;
+
+-- name: HidePhotoForRecipient :one
+-- Hide a photo from a recipient's inbox
+UPDATE photo_recipients
+SET hidden = true
+WHERE photo_id = $1 AND recipient_id = $2
+RETURNING photo_id, recipient_id, delivered_at, viewed_at, view_count, hidden;
+
+-- name: IsPhotoExpired :one
+-- Check whether a photo exists but has passed its expiry time (deleted or not)
+SELECT (expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP)::bool AS expired
+FROM photos
+WHERE id = $1;
+
+-- name: ExpirePhotos :many
+-- Mark up to @batch_size expired photos deleted and return their storage keys
+UPDATE photos
+SET is_deleted = true,
+    deleted_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id
+    FROM photos
+    WHERE is_deleted = false
+      AND expires_at <= CURRENT_TIMESTAMP
+    ORDER BY expires_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, key;
+
+-- name: SoftDeletePhoto :one
+-- Mark a photo deleted; it can be restored until it is purged
+UPDATE photos
+SET is_deleted = true,
+    deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND is_deleted = false
+RETURNING id, sender_id, photo_url, thumbnail_url, file_size, width, height, mime_type, caption, is_deleted, deleted_at, created_at, expires_at, key;
+
+-- name: GetDeletedPhotoForUpdate :one
+-- Lock a deleted photo and report whether it can still be restored
+SELECT 
+    sender_id,
+    (deleted_at > CURRENT_TIMESTAMP - make_interval(secs => @restore_window_seconds::int))::bool AS restorable,
+    (expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP)::bool AS expired
+FROM photos
+WHERE id = @id AND is_deleted = true
+FOR UPDATE;
+
+-- name: RestorePhoto :one
+-- Undo a soft delete
+UPDATE photos
+SET is_deleted = false,
+    deleted_at = NULL
+WHERE id = $1 AND is_deleted = true
+RETURNING id, sender_id, photo_url, thumbnail_url, file_size, width, height, mime_type, caption, is_deleted, deleted_at, created_at, expires_at, key;
+
+-- name: PurgeDeletedPhotos :many
+-- Permanently delete up to @batch_size photos deleted longer ago than the retention period.
+-- Their reactions and recipients are removed by ON DELETE CASCADE.
+DELETE FROM photos
+WHERE id IN (
+    SELECT id
+    FROM photos
+    WHERE is_deleted = true
+      AND deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => @retention_seconds::int)
+    ORDER BY deleted_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, key;
+
+-- name: GetPhotoAudience :one
+-- Get the sender and recipients of a photo, who receive its events
+SELECT 
+    p.sender_id,
+    COALESCE(array_agg(pr.recipient_id) FILTER (WHERE pr.recipient_id IS NOT NULL), '{}')::uuid[] AS recipient_ids
+FROM photos p
+LEFT JOIN photo_recipients pr ON pr.photo_id = p.id
+WHERE p.id = $1
+GROUP BY p.sender_id;
+
+-- name: NotifyEvent :exec
+-- Broadcast an event to every instance listening on the channel
+SELECT pg_notify(@channel::text, @payload::text);
+
+-- name: InsertOutboxEvent :one
+-- Record a domain event in the outbox, in the same transaction as the change.
+-- Its ID also identifies the event on real-time streams.
+INSERT INTO outbox_events (event_type, photo_id, payload)
+VALUES ($1, $2, $3)
+RETURNING id;
+
+-- name: ClaimOutboxEvents :many
+-- Lease up to @batch_size events that are due for delivery by moving their next
+-- attempt @lease_seconds ahead, so other relays skip them while they are delivered
+-- outside any transaction. delivered_sinks are the sinks that already have the event.
+UPDATE outbox_events e
+SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => @lease_seconds::int)
+WHERE e.id IN (
+    SELECT id
+    FROM outbox_events
+    WHERE delivered_at IS NULL
+      AND failed_at IS NULL
+      AND next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY id
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING e.id, e.event_type, e.photo_id, e.payload, e.created_at, e.attempts,
+    ARRAY(SELECT d.sink FROM outbox_deliveries d WHERE d.event_id = e.id)::text[] AS delivered_sinks;
+
+-- name: RecordOutboxDelivery :exec
+-- Record that a sink has received an event
+INSERT INTO outbox_deliveries (event_id, sink)
+VALUES ($1, $2)
+ON CONFLICT (event_id, sink) DO NOTHING;
+
+-- name: MarkOutboxEventDelivered :exec
+-- Record a successful delivery
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    delivered_at = CURRENT_TIMESTAMP,
+    last_error = NULL
+WHERE id = $1;
+
+-- name: RecordOutboxEventFailure :exec
+-- Record a failed delivery and schedule the next attempt, or give up
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    last_error = @last_error,
+    next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => @retry_after_seconds::int),
+    failed_at = CASE WHEN @give_up::bool THEN CURRENT_TIMESTAMP END
+WHERE id = @id;
+
+-- name: DeleteDeliveredOutboxEvents :execrows
+-- Remove events delivered longer ago than the retention period
+DELETE FROM outbox_events
+WHERE delivered_at <= CURRENT_TIMESTAMP - make_interval(secs => @retention_seconds::int);
+
+-- name: UpsertDeviceToken :one
+-- Register a device token. A token moves to the latest user who registers it.
+INSERT INTO device_tokens (user_id, platform, token)
+VALUES ($1, $2, $3)
+ON CONFLICT (token)
+DO UPDATE SET
+    user_id = EXCLUDED.user_id,
+    platform = EXCLUDED.platform,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING id, user_id, platform, token, created_at, updated_at;
+
+-- name: DeleteDeviceToken :execrows
+-- Unregister one of a user's device tokens
+DELETE FROM device_tokens
+WHERE user_id = $1 AND token = $2;
+
+-- name: DeleteDeviceTokens :exec
+-- Remove tokens the push provider rejected
+DELETE FROM device_tokens
+WHERE token = ANY(@tokens::text[]);
+
+-- name: ListPushTargets :many
+-- Get the device tokens of users who have not muted notifications of a kind ('reaction' or 'photo')
+SELECT d.user_id, d.platform, d.token
+FROM device_tokens d
+LEFT JOIN notification_settings s ON s.user_id = d.user_id
+WHERE d.user_id = ANY(@user_ids::uuid[])
+  AND (s.user_id IS NULL OR (
+      (s.muted_until IS NULL OR s.muted_until <= CURRENT_TIMESTAMP)
+      AND NOT (@kind::text = 'reaction' AND s.mute_reactions)
+      AND NOT (@kind::text = 'photo' AND s.mute_photos)
+  ));
+
+-- name: GetNotificationSettings :one
+-- Get a user's notification settings
+SELECT user_id, mute_reactions, mute_photos, muted_until, updated_at
+FROM notification_settings
+WHERE user_id = $1;
+
+-- name: UpsertNotificationSettings :one
+-- Create or replace a user's notification settings
+INSERT INTO notification_settings (user_id, mute_reactions, mute_photos, muted_until)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    mute_reactions = EXCLUDED.mute_reactions,
+    mute_photos = EXCLUDED.mute_photos,
+    muted_until = EXCLUDED.muted_until,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING user_id, mute_reactions, mute_photos, muted_until, updated_at;
+
+-- name: UpsertReactionNotification :exec
+-- Notify a photo's sender of a reaction. Repeat reactions from the same user collapse
+-- into one notification, which moves back to the top and becomes unread again.
+INSERT INTO notifications (user_id, actor_id, kind, photo_id, emoji)
+VALUES (@user_id, @actor_id, 'reaction', @photo_id, @emoji::text)
+ON CONFLICT (user_id, photo_id, actor_id) WHERE kind = 'reaction'
+DO UPDATE SET
+    emoji = EXCLUDED.emoji,
+    created_at = CURRENT_TIMESTAMP,
+    read_at = NULL;
+
+-- name: DeleteReactionNotification :exec
+-- Withdraw the notification of a reaction that was removed, unless the user
+-- still has other reactions to the photo
+DELETE FROM notifications
+WHERE kind = 'reaction' AND photo_id = @photo_id AND actor_id = @actor_id
+    AND NOT EXISTS (
+        SELECT 1 FROM reactions r WHERE r.photo_id = @photo_id AND r.user_id = @actor_id
+    );
+
+-- name: CreatePhotoNotifications :exec
+-- Notify the recipients of a new photo
+INSERT INTO notifications (user_id, actor_id, kind, photo_id)
+SELECT unnest(@user_ids::uuid[]), @actor_id::uuid, 'photo', @photo_id::uuid;
+
+-- name: ListNotifications :many
+-- KEYSET: Get one page of a user's notifications, newest first.
+-- Notifications about deleted or expired photos are left out.
+SELECT 
+    n.id,
+    n.user_id,
+    n.actor_id,
+    n.kind,
+    n.photo_id,
+    n.emoji,
+    n.created_at,
+    n.read_at
+FROM notifications n
+WHERE n.user_id = @user_id
+    AND EXISTS (
+        SELECT 1
+        FROM photos p
+        WHERE p.id = n.photo_id
+            AND p.is_deleted = false
+            AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+    )
+    AND (NOT @has_cursor::bool OR (n.created_at, n.id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+ORDER BY n.created_at DESC, n.id DESC
+LIMIT @page_size;
+
+-- name: CountUnreadNotifications :one
+-- Count the unread notifications a user would see in their list
+SELECT count(*)
+FROM notifications n
+WHERE n.user_id = $1
+    AND n.read_at IS NULL
+    AND EXISTS (
+        SELECT 1
+        FROM photos p
+        WHERE p.id = n.photo_id
+            AND p.is_deleted = false
+            AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP)
+    );
+
+-- name: MarkNotificationRead :one
+-- Mark one of a user's notifications as read
+UPDATE notifications
+SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+WHERE id = $1 AND user_id = $2
+RETURNING id, user_id, actor_id, kind, photo_id, emoji, created_at, read_at;
+
+-- name: MarkAllNotificationsRead :execrows
+-- Mark all of a user's unread notifications as read
+UPDATE notifications
+SET read_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: CreatePhotoReply :one
+-- Add a reply to the thread between a photo's sender and thread_user_id
+INSERT INTO photo_replies (photo_id, thread_user_id, author_id, body)
+VALUES ($1, $2, $3, $4)
+RETURNING id, photo_id, thread_user_id, author_id, body, created_at, edited_at;
+
+-- name: ListPhotoReplies :many
+-- KEYSET: Get one page of the replies to a photo, newest first, from the thread
+-- with @thread_user_id or, with @all_threads, from every thread
+SELECT 
+    id,
+    photo_id,
+    thread_user_id,
+    author_id,
+    body,
+    created_at,
+    edited_at
+FROM photo_replies
+WHERE photo_id = @photo_id
+    AND (@all_threads::bool OR thread_user_id = @thread_user_id)
+    AND (NOT @has_cursor::bool OR (created_at, id) < (@cursor_created_at::timestamp, @cursor_id::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: GetPhotoReply :one
+-- Get a reply to a visible photo together with the photo's sender
+SELECT 
+    r.id,
+    r.photo_id,
+    r.thread_user_id,
+    r.author_id,
+    r.body,
+    r.created_at,
+    r.edited_at,
+    p.sender_id as photo_sender_id
+FROM photo_replies r
+JOIN photos p ON p.id = r.photo_id
+WHERE r.id = @id AND r.photo_id = @photo_id
+    AND p.is_deleted = false
+    AND (p.expires_at IS NULL OR p.expires_at > CURRENT_TIMESTAMP);
+
+-- name: UpdatePhotoReply :one
+-- Edit the text of a reply
+UPDATE photo_replies
+SET body = $2,
+    edited_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, photo_id, thread_user_id, author_id, body, created_at, edited_at;
+
+-- name: DeletePhotoReply :exec
+-- Delete a reply
+DELETE FROM photo_replies
+WHERE id = $1;
+
+-- name: CountRepliesByPhotoIDs :many
+-- Count the replies a viewer can see on each of a page of photos: all of them
+-- on their own photos, their own thread on anyone else's
+SELECT 
+    r.photo_id,
+    COUNT(*) as reply_count
+FROM photo_replies r
+JOIN photos p ON p.id = r.photo_id
+WHERE r.photo_id = ANY(@photo_ids::uuid[])
+    AND (p.sender_id = @viewer_id OR r.thread_user_id = @viewer_id)
+GROUP BY r.photo_id;
+
+-- name: GetUserDisplayName :one
+-- Get the name a user is shown with
+SELECT display_name
+FROM users
+WHERE id = $1;
+
+-- name: GetWidgetSettings :one
+-- Get a user's widget settings
+SELECT user_id, size, show_caption, show_sender, updated_at
+FROM widget_settings
+WHERE user_id = $1;
+
+-- name: UpsertWidgetSettings :one
+-- Create or replace a user's widget settings
+INSERT INTO widget_settings (user_id, size, show_caption, show_sender)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    size = EXCLUDED.size,
+    show_caption = EXCLUDED.show_caption,
+    show_sender = EXCLUDED.show_sender,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING user_id, size, show_caption, show_sender, updated_at;
+
+-- name: GetPhotoMimeTypeByKey :one
+-- Get the verified mime type of the live photo stored under one of @keys
+SELECT mime_type
+FROM photos
+WHERE key = ANY(@keys::text[])
+    AND is_deleted = false
+    AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+LIMIT 1;
+
+-- name: IsPhotoKeyExpired :one
+-- Check whether the photo stored under one of @keys has passed its expiry time (deleted or not)
+SELECT (expires_at IS NOT NULL AND expires_at <= CURRENT_TIMESTAMP)::bool AS expired
+FROM photos
+WHERE key = ANY(@keys::text[])
+LIMIT 1;
+
+-- name: CreatePhotoUpload :one
+-- Start a direct upload that must be completed within @ttl_seconds
+INSERT INTO photo_uploads (id, sender_id, key, mime_type, file_size, sha256, expires_at)
+VALUES (@id, @sender_id, @key, @mime_type, @file_size, @sha256,
+    CURRENT_TIMESTAMP + make_interval(secs => @ttl_seconds::int))
+RETURNING id, sender_id, key, mime_type, file_size, sha256, created_at, expires_at;
+
+-- name: GetPhotoUpload :one
+-- Get one of a sender's direct uploads
+SELECT id, sender_id, key, mime_type, file_size, sha256, created_at, expires_at
+FROM photo_uploads
+WHERE id = @id AND sender_id = @sender_id;
+
+-- name: GetPhotoUploadByKey :one
+-- Get the direct upload whose staged object is stored under a key
+SELECT id, sender_id, key, mime_type, file_size, sha256, created_at, expires_at
+FROM photo_uploads
+WHERE key = $1;
+
+-- name: DeletePhotoUpload :execrows
+-- Consume a direct upload once its photo is created
+DELETE FROM photo_uploads
+WHERE id = $1;
+
+-- name: DeleteAbandonedPhotoUploads :many
+-- Delete up to @batch_size uploads that expired longer ago than @grace_seconds
+-- and return what locates their objects: the staged object under key, and the
+-- photo objects of a completion that failed, under the photo key of id
+DELETE FROM photo_uploads
+WHERE id IN (
+    SELECT id
+    FROM photo_uploads
+    WHERE expires_at <= CURRENT_TIMESTAMP - make_interval(secs => @grace_seconds::int)
+    ORDER BY expires_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, sender_id, key, mime_type;
+
+-- name: CreateResumableUpload :one
+-- Start a resumable upload
+INSERT INTO resumable_uploads (id, sender_id, upload_length, mime_type, caption, photo_expires_at, recipient_ids)
+VALUES (@id, @sender_id, @upload_length, @mime_type, @caption, @photo_expires_at, @recipient_ids::uuid[])
+RETURNING id, sender_id, upload_length, upload_offset, mime_type, caption, photo_expires_at,
+    recipient_ids, created_at, updated_at, completed_at;
+
+-- name: GetResumableUpload :one
+-- Get one of a sender's resumable uploads
+SELECT id, sender_id, upload_length, upload_offset, mime_type, caption, photo_expires_at,
+    recipient_ids, created_at, updated_at, completed_at
+FROM resumable_uploads
+WHERE id = @id AND sender_id = @sender_id;
+
+-- name: AdvanceResumableUpload :execrows
+-- Record received bytes, unless another request moved the offset first
+UPDATE resumable_uploads
+SET upload_offset = @new_offset,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = @id AND upload_offset = @old_offset AND completed_at IS NULL;
+
+-- name: CompleteResumableUpload :execrows
+-- Mark a resumable upload turned into a photo
+UPDATE resumable_uploads
+SET completed_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND completed_at IS NULL;
+
+-- name: DeleteResumableUpload :execrows
+-- Terminate one of a sender's resumable uploads
+DELETE FROM resumable_uploads
+WHERE id = @id AND sender_id = @sender_id;
+
+-- name: DeleteStaleResumableUploads :many
+-- Delete up to @batch_size uploads untouched for @ttl_seconds
+DELETE FROM resumable_uploads
+WHERE id IN (
+    SELECT id
+    FROM resumable_uploads
+    WHERE updated_at <= CURRENT_TIMESTAMP - make_interval(secs => @ttl_seconds::int)
+    ORDER BY updated_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, sender_id, mime_type, completed_at;
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxBurstCount caps how often a user can repeat a reaction in ReactionModeBurst
+const MaxBurstCount = 99
+
+// ReactionMode controls how many reactions a user can leave on a photo
+type ReactionMode string
+
+const (
+	// ReactionModeSingle keeps one emoji per user; reacting again replaces it
+	ReactionModeSingle ReactionMode = "single"
+	// ReactionModeStack lets a user react with several distinct emojis
+	ReactionModeStack ReactionMode = "stack"
+	// ReactionModeBurst is ReactionModeStack where repeating an emoji increases its burst_count
+	ReactionModeBurst ReactionMode = "burst"
+)
+
+// AllowedReactions describes which emojis can be used as reactions
+type AllowedReactions struct {
+	Mode       ReactionMode `json:"mode"`
+	Restricted bool         `json:"restricted"` // false when any single emoji is accepted
+	Emojis     []string     `json:"emojis"`     // the allowed emojis, in configured order
+}
+
+// reactionPolicy is the configured reaction mode and allowlist of reaction emojis
+type reactionPolicy struct {
+	mode   ReactionMode
+	emojis []string
+	// allowed maps each normalized emoji to its configured form
+	allowed map[string]string
+}
+
+// SetAllowedReactions restricts reactions to the given emojis.
+// An empty list accepts any single emoji.
+func (s *PhotoService) SetAllowedReactions(emojis []string) error {
+	policy := reactionPolicy{mode: s.reactionPolicy.mode, emojis: make([]string, 0, len(emojis))}
+	if len(emojis) > 0 {
+		policy.allowed = make(map[string]string, len(emojis))
+	}
+	for _, e := range emojis {
+		e = strings.TrimSpace(e)
+		if !IsSingleEmoji(e) {
+			return fmt.Errorf("allowed reaction %q is not a single emoji", e)
+		}
+		if _, dup := policy.allowed[normalizeEmoji(e)]; !dup {
+			policy.allowed[normalizeEmoji(e)] = e
+			policy.emojis = append(policy.emojis, e)
+		}
+	}
+	s.reactionPolicy = policy
+	return nil
+}
+
+// SetReactionMode selects how many reactions a user can leave on a photo.
+// The default is ReactionModeSingle.
+func (s *PhotoService) SetReactionMode(mode ReactionMode) error {
+	switch mode {
+	case ReactionModeSingle, ReactionModeStack, ReactionModeBurst:
+		s.reactionPolicy.mode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown reaction mode %q", mode)
+}
+
+// AllowedReactions returns the reaction mode and the emojis users can react with
+func (s *PhotoService) AllowedReactions() AllowedReactions {
+	return AllowedReactions{
+		Mode:       s.reactionPolicy.mode,
+		Restricted: s.reactionPolicy.allowed != nil,
+		Emojis:     append(make([]string, 0, len(s.reactionPolicy.emojis)), s.reactionPolicy.emojis...),
+	}
+}
+
+// checkEmoji validates a reaction emoji and returns the form to store. With an
+// allowlist, variants of an allowed emoji (e.g. without its presentation selector)
+// are stored as configured so they are counted together.
+func (s *PhotoService) checkEmoji(emoji string) (string, error) {
+	if emoji == "" {
+		return "", &ValidationError{Field: "emoji", Message: "emoji is required"}
+	}
+	if !IsSingleEmoji(emoji) {
+		return "", &ValidationError{Field: "emoji", Message: "emoji must be a single emoji"}
+	}
+	if s.reactionPolicy.allowed == nil {
+		return emoji, nil
+	}
+	allowed, ok := s.reactionPolicy.allowed[normalizeEmoji(emoji)]
+	if !ok {
+		return "", &ValidationError{Field: "emoji", Message: "emoji is not an allowed reaction"}
+	}
+	return allowed, nil
+}
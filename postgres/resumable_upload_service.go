@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/yourproject/db" // Update with your actual path
+)
+
+// DefaultResumableUploadTTL is how long a resumable upload can sit idle before it is removed
+const DefaultResumableUploadTTL = 24 * time.Hour
+
+// ResumableUpload is the state of a resumable upload
+type ResumableUpload struct {
+	ID     uuid.UUID
+	Length int64
+	Offset int64 // bytes received so far
+	// PhotoID is set once all bytes are received and the photo is created
+	PhotoID *uuid.UUID
+}
+
+// CreateResumableUploadParams declares a photo to upload in chunks
+type CreateResumableUploadParams struct {
+	SenderID  uuid.UUID
+	Length    int64
+	MimeType  string
+	Caption   *string
+	ExpiresAt *time.Time
+	// RecipientIDs are the friends the photo is sent to.
+	// An empty list sends the photo to all of the sender's friends at completion.
+	RecipientIDs []uuid.UUID
+}
+
+// ResumableUploadStore is the part of db.Queries used by ResumableUploadService
+type ResumableUploadStore interface {
+	CreateResumableUpload(ctx context.Context, arg db.CreateResumableUploadParams) (db.ResumableUpload, error)
+	GetResumableUpload(ctx context.Context, arg db.GetResumableUploadParams) (db.ResumableUpload, error)
+	AdvanceResumableUpload(ctx context.Context, arg db.AdvanceResumableUploadParams) (int64, error)
+	DeleteResumableUpload(ctx context.Context, arg db.DeleteResumableUploadParams) (int64, error)
+	DeleteStaleResumableUploads(ctx context.Context, arg db.DeleteStaleResumableUploadsParams) ([]db.DeleteStaleResumableUploadsRow, error)
+	ListFriends(ctx context.Context, userID uuid.UUID) ([]db.ListFriendsRow, error)
+	CountFriendsAmong(ctx context.Context, arg db.CountFriendsAmongParams) (int64, error)
+}
+
+// ResumableUploadService receives photos in chunks that can be resumed after a
+// failure (the tus protocol). Chunks are appended to a file in a local directory
+// and the offset is recorded in the database, so uploads survive restarts.
+// Complete uploads become photos through the same path as PhotoService.CreatePhoto.
+type ResumableUploadService struct {
+	photos  *PhotoService
+	queries ResumableUploadStore
+	dir     string
+	ttl     time.Duration
+
+	mu     sync.Mutex
+	active map[uuid.UUID]bool // uploads a request is writing to
+}
+
+// NewResumableUploadService creates a service keeping partial uploads in dir
+func NewResumableUploadService(photos *PhotoService, queries ResumableUploadStore, dir string) (*ResumableUploadService, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &ResumableUploadService{
+		photos:  photos,
+		queries: queries,
+		dir:     dir,
+		ttl:     DefaultResumableUploadTTL,
+		active:  make(map[uuid.UUID]bool),
+	}, nil
+}
+
+// SetTTL sets how long an upload can sit idle before it is removed
+func (s *ResumableUploadService) SetTTL(ttl time.Duration) {
+	s.ttl = ttl
+}
+
+// Create starts a resumable upload with an empty file
+func (s *ResumableUploadService) Create(ctx context.Context, params CreateResumableUploadParams) (*ResumableUpload, error) {
+	if !IsSupportedPhotoType(params.MimeType) {
+		return nil, &ValidationError{Field: "filetype", Message: fmt.Sprintf("unsupported mime type %q", params.MimeType)}
+	}
+	if params.Length <= 0 || params.Length > MaxPhotoSize {
+		return nil, &ValidationError{Field: "upload_length", Message: fmt.Sprintf("upload length must be between 1 and %d bytes", MaxPhotoSize)}
+	}
+	if len(params.RecipientIDs) > 0 {
+		// Fail now rather than after the whole photo is uploaded
+		if _, err := resolveRecipients(ctx, s.queries, params.SenderID, params.RecipientIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	id := uuid.New()
+	f, err := os.OpenFile(s.path(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	f.Close()
+
+	recipientIDs := params.RecipientIDs
+	if recipientIDs == nil {
+		recipientIDs = []uuid.UUID{}
+	}
+	upload, err := s.queries.CreateResumableUpload(ctx, db.CreateResumableUploadParams{
+		ID:             id,
+		SenderID:       params.SenderID,
+		UploadLength:   int32(params.Length),
+		MimeType:       params.MimeType,
+		Caption:        params.Caption,
+		PhotoExpiresAt: params.ExpiresAt,
+		RecipientIds:   recipientIDs,
+	})
+	if err != nil {
+		os.Remove(s.path(id))
+		return nil, mapDBError(err, "upload", "create upload")
+	}
+	return newResumableUpload(upload), nil
+}
+
+// Get returns the state of one of senderID's uploads
+func (s *ResumableUploadService) Get(ctx context.Context, id, senderID uuid.UUID) (*ResumableUpload, error) {
+	upload, err := s.queries.GetResumableUpload(ctx, db.GetResumableUploadParams{ID: id, SenderID: senderID})
+	if err != nil {
+		return nil, mapDBError(err, "upload", "get upload")
+	}
+	return newResumableUpload(upload), nil
+}
+
+// Append writes a chunk read from body at offset, which must be the number of bytes
+// received so far. Bytes received before body fails are kept, so the client can
+// resume from the new offset; a chunk longer than the rest of the upload is
+// rejected as a whole. The photo is created once the last byte arrives.
+func (s *ResumableUploadService) Append(ctx context.Context, id, senderID uuid.UUID, offset int64, body io.Reader) (*ResumableUpload, error) {
+	if !s.acquire(id) {
+		return nil, fmt.Errorf("%w: another request is writing to this upload", ErrConflict)
+	}
+	defer s.release(id)
+
+	upload, err := s.queries.GetResumableUpload(ctx, db.GetResumableUploadParams{ID: id, SenderID: senderID})
+	if err != nil {
+		return nil, mapDBError(err, "upload", "get upload")
+	}
+	if offset != int64(upload.UploadOffset) {
+		return nil, fmt.Errorf("%w: upload offset is %d", ErrConflict, upload.UploadOffset)
+	}
+	if upload.CompletedAt != nil {
+		return newResumableUpload(upload), nil
+	}
+
+	// Read one byte more than missing to tell a chunk that is too long. Its bytes
+	// are past the recorded offset, so the next write drops them.
+	remaining := int64(upload.UploadLength) - offset
+	n, copyErr := s.write(id, offset, io.LimitReader(body, remaining+1))
+	if n > remaining {
+		return nil, &ValidationError{Field: "body", Message: "chunk is longer than the rest of the upload"}
+	}
+	if n > 0 {
+		rows, err := s.queries.AdvanceResumableUpload(ctx, db.AdvanceResumableUploadParams{
+			ID:        id,
+			OldOffset: upload.UploadOffset,
+			NewOffset: upload.UploadOffset + int32(n),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to record upload offset: %w", err)
+		}
+		if rows == 0 {
+			return nil, fmt.Errorf("%w: upload offset changed", ErrConflict)
+		}
+		upload.UploadOffset += int32(n)
+	}
+	if copyErr != nil {
+		return nil, copyErr
+	}
+	if upload.UploadOffset < upload.UploadLength {
+		return newResumableUpload(upload), nil
+	}
+
+	if err := s.complete(ctx, upload); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	upload.CompletedAt = &now
+	return newResumableUpload(upload), nil
+}
+
+// write appends r to the upload file at offset and flushes it to disk, returning
+// the number of bytes written
+func (s *ResumableUploadService) write(id uuid.UUID, offset int64, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.path(id), os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%w: upload data is no longer available", ErrExpired)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer f.Close()
+
+	// Drop bytes written past the recorded offset, e.g. before a crash
+	if err := f.Truncate(offset); err != nil {
+		return 0, fmt.Errorf("failed to truncate upload file: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek upload file: %w", err)
+	}
+
+	n, copyErr := io.Copy(f, r)
+	if err := f.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to flush upload file: %w", err)
+	}
+	if copyErr != nil {
+		copyErr = fmt.Errorf("failed to receive chunk: %w", copyErr)
+	}
+	return n, copyErr
+}
+
+// complete creates the photo of a fully received upload and removes its file
+func (s *ResumableUploadService) complete(ctx context.Context, upload db.ResumableUpload) error {
+	data, err := os.ReadFile(s.path(upload.ID))
+	if err != nil {
+		return fmt.Errorf("failed to read upload file: %w", err)
+	}
+	photo, err := inspectUpload(data, upload.MimeType)
+	if err != nil {
+		return err
+	}
+
+	params := CreatePhotoParams{
+		SenderID:     upload.SenderID,
+		MimeType:     upload.MimeType,
+		Caption:      upload.Caption,
+		ExpiresAt:    upload.PhotoExpiresAt,
+		RecipientIDs: upload.RecipientIds,
+	}
+	key := photoKey(upload.SenderID, upload.ID, photoExtensions[upload.MimeType])
+	_, err = s.photos.savePhoto(ctx, params, upload.ID, key, photo, &completedUpload{
+		consume: func(q *db.Queries) error {
+			n, err := q.CompleteResumableUpload(ctx, upload.ID)
+			if err != nil {
+				return fmt.Errorf("failed to complete upload: %w", err)
+			}
+			if n == 0 {
+				return fmt.Errorf("%w: upload is already complete", ErrConflict)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s.removeFile(upload.ID)
+	return nil
+}
+
+// Terminate cancels one of senderID's uploads and removes its data.
+// Terminating a complete upload keeps its photo.
+func (s *ResumableUploadService) Terminate(ctx context.Context, id, senderID uuid.UUID) error {
+	if !s.acquire(id) {
+		return fmt.Errorf("%w: another request is writing to this upload", ErrConflict)
+	}
+	defer s.release(id)
+
+	n, err := s.queries.DeleteResumableUpload(ctx, db.DeleteResumableUploadParams{ID: id, SenderID: senderID})
+	if err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	if n == 0 {
+		return &NotFoundError{Resource: "upload"}
+	}
+	s.removeFile(id)
+	return nil
+}
+
+// CollectStaleUploads removes uploads untouched for longer than the TTL with their
+// files. Objects stored by a failed completion are removed too. It returns the
+// number of uploads removed.
+func (s *ResumableUploadService) CollectStaleUploads(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		rows, err := s.queries.DeleteStaleResumableUploads(ctx, db.DeleteStaleResumableUploadsParams{
+			TtlSeconds: int32(s.ttl.Seconds()),
+			BatchSize:  uploadBatchSize,
+		})
+		if err != nil {
+			return total, fmt.Errorf("failed to collect resumable uploads: %w", err)
+		}
+		for _, row := range rows {
+			s.removeFile(row.ID)
+			if row.CompletedAt == nil {
+				s.photos.deleteObjects(ctx, photoKey(row.SenderID, row.ID, photoExtensions[row.MimeType]))
+			}
+		}
+		total += len(rows)
+		if len(rows) < uploadBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// RunCollector collects stale uploads every interval until ctx is canceled
+func (s *ResumableUploadService) RunCollector(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		n, err := s.CollectStaleUploads(ctx)
+		if err != nil {
+			log.Printf("resumable upload collector: %v", err)
+		}
+		if n > 0 {
+			log.Printf("resumable upload collector: removed %d stale uploads", n)
+		}
+	})
+}
+
+// acquire marks an upload as being written to, reporting false if it already is
+func (s *ResumableUploadService) acquire(id uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[id] {
+		return false
+	}
+	s.active[id] = true
+	return true
+}
+
+// release ends a write started with acquire
+func (s *ResumableUploadService) release(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
+
+// path returns the file the chunks of an upload are appended to
+func (s *ResumableUploadService) path(id uuid.UUID) string {
+	return filepath.Join(s.dir, id.String())
+}
+
+// removeFile deletes the file of an upload; a missing file is not an error
+func (s *ResumableUploadService) removeFile(id uuid.UUID) {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed to delete upload file %s: %v", id, err)
+	}
+}
+
+// newResumableUpload converts a resumable_uploads row to its state
+func newResumableUpload(u db.ResumableUpload) *ResumableUpload {
+	upload := &ResumableUpload{
+		ID:     u.ID,
+		Length: int64(u.UploadLength),
+		Offset: int64(u.UploadOffset),
+	}
+	if u.CompletedAt != nil {
+		upload.PhotoID = &u.ID
+	}
+	return upload
+}
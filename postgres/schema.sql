@@ -1,6 +1,8 @@
 -- public.users definition (referenced by foreign keys)
 CREATE TABLE IF NOT EXISTS public.users (
     id uuid DEFAULT gen_random_uuid() NOT NULL,
+    -- The name shown for a user, e.g. as a photo's sender on home-screen widgets
+    display_name varchar(64) NULL,
     CONSTRAINT users_pkey PRIMARY KEY (id)
 );
 
@@ -13,12 +15,12 @@ CREATE TABLE public.photos (
     file_size int4 NULL,
     width int4 NULL,
     height int4 NULL,
-    mime_type varchar(50) DEFAULT 'image/jpeg'::character varying NULL,
+    mime_type varchar(50) NULL,
     caption text NULL,
     is_deleted bool DEFAULT false NULL,
     deleted_at timestamp NULL,
     created_at timestamp DEFAULT CURRENT_TIMESTAMP NULL,
-    expires_at timestamp NULL,
+    expires_at timestamptz NULL,
     "key" varchar(255) NULL,
     CONSTRAINT photos_pkey PRIMARY KEY (id),
     CONSTRAINT photos_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES public.users(id) ON DELETE CASCADE
@@ -26,20 +28,220 @@ CREATE TABLE public.photos (
 
 CREATE INDEX idx_photos_created ON public.photos USING btree (created_at);
 CREATE INDEX idx_photos_deleted ON public.photos USING btree (is_deleted);
-CREATE INDEX idx_photos_sender ON public.photos USING btree (sender_id, created_at);
+CREATE INDEX idx_photos_sender ON public.photos USING btree (sender_id, created_at, id);
+CREATE INDEX idx_photos_expires ON public.photos USING btree (expires_at) WHERE is_deleted = false AND expires_at IS NOT NULL;
+CREATE INDEX idx_photos_key ON public.photos USING btree ("key");
 
 -- public.reactions definition
 CREATE TABLE public.reactions (
     id uuid DEFAULT gen_random_uuid() NOT NULL,
     photo_id uuid NOT NULL,
     user_id uuid NOT NULL,
-    emoji varchar(10) NOT NULL,
+    emoji varchar(32) NOT NULL,
     created_at timestamp DEFAULT CURRENT_TIMESTAMP NULL,
+    burst_count int4 DEFAULT 1 NOT NULL,
     CONSTRAINT reactions_pkey PRIMARY KEY (id),
-    CONSTRAINT unique_user_photo_reaction UNIQUE (photo_id, user_id),
+    -- A user can react with several distinct emojis; ReactionMode decides how many are kept
+    CONSTRAINT unique_user_photo_emoji_reaction UNIQUE (photo_id, user_id, emoji),
     CONSTRAINT reactions_photo_id_fkey FOREIGN KEY (photo_id) REFERENCES public.photos(id) ON DELETE CASCADE,
     CONSTRAINT reactions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
 );
 
 CREATE INDEX idx_reactions_photo ON public.reactions USING btree (photo_id);
 CREATE INDEX idx_reactions_user ON public.reactions USING btree (user_id);
+
+-- public.friendships definition
+-- One row per pair of users: requester_id asked addressee_id to be friends.
+-- A 'blocked' row is always owned by the blocker (requester_id).
+CREATE TABLE public.friendships (
+    requester_id uuid NOT NULL,
+    addressee_id uuid NOT NULL,
+    status varchar(20) DEFAULT 'pending'::character varying NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT friendships_pkey PRIMARY KEY (requester_id, addressee_id),
+    CONSTRAINT friendships_not_self CHECK (requester_id <> addressee_id),
+    CONSTRAINT friendships_status_check CHECK (status IN ('pending', 'accepted', 'declined', 'blocked')),
+    CONSTRAINT friendships_requester_id_fkey FOREIGN KEY (requester_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    CONSTRAINT friendships_addressee_id_fkey FOREIGN KEY (addressee_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+-- At most one relationship per pair, whichever direction it was requested in
+CREATE UNIQUE INDEX idx_friendships_pair ON public.friendships USING btree (LEAST(requester_id, addressee_id), GREATEST(requester_id, addressee_id));
+CREATE INDEX idx_friendships_addressee ON public.friendships USING btree (addressee_id, status);
+CREATE INDEX idx_friendships_requester ON public.friendships USING btree (requester_id, status);
+
+-- public.photo_recipients definition
+-- The friends a photo was sent to, with per-recipient delivery state
+CREATE TABLE public.photo_recipients (
+    photo_id uuid NOT NULL,
+    recipient_id uuid NOT NULL,
+    delivered_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    viewed_at timestamp NULL,
+    view_count int4 DEFAULT 0 NOT NULL,
+    hidden bool DEFAULT false NOT NULL,
+    CONSTRAINT photo_recipients_pkey PRIMARY KEY (photo_id, recipient_id),
+    CONSTRAINT photo_recipients_photo_id_fkey FOREIGN KEY (photo_id) REFERENCES public.photos(id) ON DELETE CASCADE,
+    CONSTRAINT photo_recipients_recipient_id_fkey FOREIGN KEY (recipient_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_photo_recipients_recipient ON public.photo_recipients USING btree (recipient_id, hidden);
+
+-- public.outbox_events definition
+-- Domain events written in the same transaction as the change they describe,
+-- then delivered to sinks (notifications, analytics, webhooks) by the outbox relay
+CREATE TABLE public.outbox_events (
+    id bigserial NOT NULL,
+    event_type varchar(50) NOT NULL,
+    photo_id uuid NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    attempts int4 DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    last_error text NULL,
+    delivered_at timestamp NULL,
+    failed_at timestamp NULL,
+    CONSTRAINT outbox_events_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_outbox_events_pending ON public.outbox_events USING btree (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_events_delivered ON public.outbox_events USING btree (delivered_at) WHERE delivered_at IS NOT NULL;
+
+-- public.outbox_deliveries definition
+-- The sinks an outbox event has reached, so a retry only goes to the sinks that failed
+CREATE TABLE public.outbox_deliveries (
+    event_id int8 NOT NULL,
+    sink varchar(50) NOT NULL,
+    delivered_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT outbox_deliveries_pkey PRIMARY KEY (event_id, sink),
+    CONSTRAINT outbox_deliveries_event_id_fkey FOREIGN KEY (event_id) REFERENCES public.outbox_events(id) ON DELETE CASCADE
+);
+
+-- public.device_tokens definition
+-- Push notification tokens of users' devices
+CREATE TABLE public.device_tokens (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    user_id uuid NOT NULL,
+    platform varchar(10) NOT NULL,
+    "token" text NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT device_tokens_pkey PRIMARY KEY (id),
+    CONSTRAINT device_tokens_token_key UNIQUE ("token"),
+    CONSTRAINT device_tokens_platform_check CHECK (platform IN ('ios', 'android', 'web')),
+    CONSTRAINT device_tokens_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_device_tokens_user ON public.device_tokens USING btree (user_id);
+
+-- public.notification_settings definition
+-- Per-user push notification preferences; users without a row get every notification
+CREATE TABLE public.notification_settings (
+    user_id uuid NOT NULL,
+    mute_reactions bool DEFAULT false NOT NULL,
+    mute_photos bool DEFAULT false NOT NULL,
+    muted_until timestamptz NULL,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT notification_settings_pkey PRIMARY KEY (user_id),
+    CONSTRAINT notification_settings_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+-- public.notifications definition
+-- In-app notification inbox: reactions to a user's photos and photos sent to them
+CREATE TABLE public.notifications (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    user_id uuid NOT NULL,
+    actor_id uuid NOT NULL,
+    kind varchar(20) NOT NULL,
+    photo_id uuid NOT NULL,
+    emoji varchar(32) NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    read_at timestamp NULL,
+    CONSTRAINT notifications_pkey PRIMARY KEY (id),
+    CONSTRAINT notifications_kind_check CHECK (kind IN ('reaction', 'photo')),
+    CONSTRAINT notifications_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    CONSTRAINT notifications_actor_id_fkey FOREIGN KEY (actor_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    CONSTRAINT notifications_photo_id_fkey FOREIGN KEY (photo_id) REFERENCES public.photos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user ON public.notifications USING btree (user_id, created_at, id);
+CREATE INDEX idx_notifications_unread ON public.notifications USING btree (user_id) WHERE read_at IS NULL;
+-- Repeat reactions from the same user to the same photo collapse into one notification
+CREATE UNIQUE INDEX idx_notifications_reaction ON public.notifications USING btree (user_id, photo_id, actor_id) WHERE kind = 'reaction';
+
+-- public.photo_replies definition
+-- Text replies to a photo. Each thread is a private conversation between the
+-- photo's sender and one friend (thread_user_id); the sender sees every thread.
+CREATE TABLE public.photo_replies (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    photo_id uuid NOT NULL,
+    thread_user_id uuid NOT NULL,
+    author_id uuid NOT NULL,
+    body text NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    edited_at timestamp NULL,
+    CONSTRAINT photo_replies_pkey PRIMARY KEY (id),
+    CONSTRAINT photo_replies_body_check CHECK (char_length(body) BETWEEN 1 AND 1000),
+    CONSTRAINT photo_replies_photo_id_fkey FOREIGN KEY (photo_id) REFERENCES public.photos(id) ON DELETE CASCADE,
+    CONSTRAINT photo_replies_thread_user_id_fkey FOREIGN KEY (thread_user_id) REFERENCES public.users(id) ON DELETE CASCADE,
+    CONSTRAINT photo_replies_author_id_fkey FOREIGN KEY (author_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_photo_replies_thread ON public.photo_replies USING btree (photo_id, thread_user_id, created_at, id);
+CREATE INDEX idx_photo_replies_photo ON public.photo_replies USING btree (photo_id, created_at, id);
+
+-- public.widget_settings definition
+-- Per-user home-screen widget preferences; users without a row get the defaults
+CREATE TABLE public.widget_settings (
+    user_id uuid NOT NULL,
+    "size" varchar(10) DEFAULT 'small' NOT NULL,
+    show_caption bool DEFAULT true NOT NULL,
+    show_sender bool DEFAULT true NOT NULL,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT widget_settings_pkey PRIMARY KEY (user_id),
+    CONSTRAINT widget_settings_size_check CHECK ("size" IN ('small', 'medium', 'large')),
+    CONSTRAINT widget_settings_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+-- public.photo_uploads definition
+-- Direct uploads in progress: the client PUTs the photo to a signed URL for the
+-- staging "key", then completes the upload, which creates the photo with the
+-- upload's ID under its own key.
+-- expires_at is checked against the API server's clock, so it is a timestamptz.
+CREATE TABLE public.photo_uploads (
+    id uuid NOT NULL,
+    sender_id uuid NOT NULL,
+    "key" text NOT NULL,
+    mime_type varchar(50) NOT NULL,
+    file_size int4 NOT NULL,
+    sha256 char(64) NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at timestamptz NOT NULL,
+    CONSTRAINT photo_uploads_pkey PRIMARY KEY (id),
+    CONSTRAINT photo_uploads_key_key UNIQUE ("key"),
+    CONSTRAINT photo_uploads_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_photo_uploads_expires ON public.photo_uploads USING btree (expires_at);
+
+-- public.resumable_uploads definition
+-- tus uploads in progress. Chunks are appended to a local file; upload_offset is
+-- the number of bytes received so far. The photo gets the upload's ID.
+CREATE TABLE public.resumable_uploads (
+    id uuid NOT NULL,
+    sender_id uuid NOT NULL,
+    upload_length int4 NOT NULL,
+    upload_offset int4 DEFAULT 0 NOT NULL,
+    mime_type varchar(50) NOT NULL,
+    caption text NULL,
+    photo_expires_at timestamptz NULL,
+    recipient_ids uuid[] DEFAULT '{}' NOT NULL,
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    completed_at timestamp NULL,
+    CONSTRAINT resumable_uploads_pkey PRIMARY KEY (id),
+    CONSTRAINT resumable_uploads_offset_check CHECK (upload_offset BETWEEN 0 AND upload_length),
+    CONSTRAINT resumable_uploads_sender_id_fkey FOREIGN KEY (sender_id) REFERENCES public.users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_resumable_uploads_updated ON public.resumable_uploads USING btree (updated_at);
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path"
+	"strings"
+)
+
+// ThumbnailVariant describes a resized rendition generated for every photo
+type ThumbnailVariant struct {
+	Name    string // appended to the original key
+	MaxSide int    // longest side in pixels
+	Quality int    // JPEG quality
+}
+
+// DefaultThumbnailVariants are the renditions our clients request
+var DefaultThumbnailVariants = []ThumbnailVariant{
+	{Name: "widget-small", MaxSide: 256, Quality: 80},
+	{Name: "widget-large", MaxSide: 512, Quality: 82},
+	{Name: "feed", MaxSide: 1080, Quality: 85},
+}
+
+// PrimaryThumbnailVariant is the rendition recorded in photos.thumbnail_url
+const PrimaryThumbnailVariant = "widget-small"
+
+// LargeWidgetThumbnailVariant is the rendition shown on medium and large widgets
+const LargeWidgetThumbnailVariant = "widget-large"
+
+// Thumbnail is an encoded rendition of a photo
+type Thumbnail struct {
+	Variant string
+	Key     string
+	Data    []byte
+	Width   int
+	Height  int
+}
+
+// ThumbnailKey returns the key a variant of the photo stored under key is stored at.
+// Thumbnails live next to the original: photos/a/b.png -> photos/a/b_feed.jpg
+func ThumbnailKey(key, variant string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + variant + ".jpg"
+}
+
+// Thumbnailer generates the configured thumbnail variants
+type Thumbnailer struct {
+	variants []ThumbnailVariant
+}
+
+// NewThumbnailer creates a thumbnailer for the given variants
+func NewThumbnailer(variants []ThumbnailVariant) *Thumbnailer {
+	return &Thumbnailer{variants: variants}
+}
+
+// Keys returns the keys of all thumbnails of the photo stored under key
+func (t *Thumbnailer) Keys(key string) []string {
+	keys := make([]string, 0, len(t.variants))
+	for _, v := range t.variants {
+		keys = append(keys, ThumbnailKey(key, v.Name))
+	}
+	return keys
+}
+
+// PhotoKeys returns the keys the photo of a thumbnail may be stored under, one
+// per photo type, or nil if key is not the key of a thumbnail variant
+func (t *Thumbnailer) PhotoKeys(key string) []string {
+	for _, v := range t.variants {
+		base, ok := strings.CutSuffix(key, "_"+v.Name+".jpg")
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(photoExtensions))
+		for _, ext := range photoExtensions {
+			keys = append(keys, base+ext)
+		}
+		return keys
+	}
+	return nil
+}
+
+// Generate renders every variant of img, turned upright by its EXIF orientation, as JPEG.
+// Images are never upscaled: a variant larger than the original keeps the original size.
+// The original is downscaled once to the largest variant and the others are resized
+// from that, so no full-size copy of the decoded image is made.
+func (t *Thumbnailer) Generate(key string, img image.Image, orientation int) ([]Thumbnail, error) {
+	largest := 0
+	for _, v := range t.variants {
+		largest = max(largest, v.MaxSide)
+	}
+	w, h := fitWithin(img.Bounds().Dx(), img.Bounds().Dy(), largest)
+	src := orientImage(resizeBox(img, w, h), orientation)
+
+	thumbs := make([]Thumbnail, 0, len(t.variants))
+	for _, v := range t.variants {
+		w, h := fitWithin(src.Bounds().Dx(), src.Bounds().Dy(), v.MaxSide)
+		resized := resizeBox(src, w, h)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: v.Quality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s thumbnail: %w", v.Name, err)
+		}
+		thumbs = append(thumbs, Thumbnail{
+			Variant: v.Name,
+			Key:     ThumbnailKey(key, v.Name),
+			Data:    buf.Bytes(),
+			Width:   w,
+			Height:  h,
+		})
+	}
+	return thumbs, nil
+}
+
+// fitWithin scales w x h down so the longest side is at most maxSide
+func fitWithin(w, h, maxSide int) (int, int) {
+	if w <= maxSide && h <= maxSide {
+		return w, h
+	}
+	if w >= h {
+		return maxSide, max(1, h*maxSide/w)
+	}
+	return max(1, w*maxSide/h), maxSide
+}
+
+// resizeBox downscales src to w x h by averaging the source pixels
+// covered by each destination pixel (box filter). Source rows are converted
+// one at a time, so src is never copied as a whole.
+func resizeBox(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if rgba, ok := src.(*image.RGBA); ok && sw == w && sh == h && b.Min == (image.Point{}) {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	row := make([]uint8, sw*4)
+	sums := make([]uint64, w*4)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		clear(sums)
+		for sy := y0; sy < y1; sy++ {
+			readRow(src, b.Min.Y+sy, row)
+			for x := 0; x < w; x++ {
+				x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+				s := sums[x*4 : x*4+4]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					s[0] += uint64(p[0])
+					s[1] += uint64(p[1])
+					s[2] += uint64(p[2])
+					s[3] += uint64(p[3])
+				}
+			}
+		}
+
+		for x := 0; x < w; x++ {
+			n := uint64((y1 - y0) * (max((x+1)*sw/w, x*sw/w+1) - x*sw/w))
+			s := sums[x*4 : x*4+4]
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			d[0] = uint8(s[0] / n)
+			d[1] = uint8(s[1] / n)
+			d[2] = uint8(s[2] / n)
+			d[3] = uint8(s[3] / n)
+		}
+	}
+	return dst
+}
+
+// readRow converts row y of img to premultiplied RGBA pixels in row
+func readRow(img image.Image, y int, row []uint8) {
+	b := img.Bounds()
+	switch img := img.(type) {
+	case *image.RGBA:
+		copy(row, img.Pix[img.PixOffset(b.Min.X, y):])
+	case *image.NRGBA:
+		pix := img.Pix[img.PixOffset(b.Min.X, y):]
+		for i := 0; i < len(row); i += 4 {
+			a := uint32(pix[i+3])
+			row[i] = uint8(uint32(pix[i]) * a / 0xFF)
+			row[i+1] = uint8(uint32(pix[i+1]) * a / 0xFF)
+			row[i+2] = uint8(uint32(pix[i+2]) * a / 0xFF)
+			row[i+3] = uint8(a)
+		}
+	case *image.YCbCr: // decoded JPEGs
+		for x := 0; x < b.Dx(); x++ {
+			yi, ci := img.YOffset(b.Min.X+x, y), img.COffset(b.Min.X+x, y)
+			r, g, bl := color.YCbCrToRGB(img.Y[yi], img.Cb[ci], img.Cr[ci])
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = r, g, bl, 0xFF
+		}
+	default:
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, a := img.At(b.Min.X+x, y).RGBA()
+			row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = uint8(r>>8), uint8(g>>8), uint8(bl>>8), uint8(a>>8)
+		}
+	}
+}
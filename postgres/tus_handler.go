@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// TusVersion is the version of the tus resumable upload protocol served by TusHandler
+const TusVersion = "1.0.0"
+
+// tusExtensions are the tus protocol extensions supported by TusHandler
+const tusExtensions = "creation,termination"
+
+// TusHandler implements the tus resumable upload protocol (https://tus.io) for photos.
+// Once the last chunk arrives the photo is created with the upload's ID.
+type TusHandler struct {
+	uploads  *service.ResumableUploadService
+	basePath string
+}
+
+// NewTusHandler creates a tus handler whose uploads live below basePath
+// (e.g. "/api/v1/uploads/tus/"), used to build their Location
+func NewTusHandler(uploads *service.ResumableUploadService, basePath string) *TusHandler {
+	return &TusHandler{
+		uploads:  uploads,
+		basePath: strings.TrimRight(basePath, "/") + "/",
+	}
+}
+
+// Options godoc
+// @Summary Describe the tus server
+// @Description Report the tus version, extensions and maximum upload size
+// @Tags uploads
+// @Success 204
+// @Header 204 {string} Tus-Version "Supported protocol versions"
+// @Header 204 {string} Tus-Extension "Supported extensions"
+// @Header 204 {integer} Tus-Max-Size "Maximum upload length in bytes"
+// @Router /uploads/tus [options]
+func (h *TusHandler) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	w.Header().Set("Tus-Version", TusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.Itoa(service.MaxPhotoSize))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+// @Summary Start a resumable photo upload
+// @Description Create a tus upload. Upload-Metadata must carry filetype (the photo's mime type) and may carry
+// @Description caption, expires_at (RFC 3339) and recipient_ids (comma-separated; all friends if omitted).
+// @Tags uploads
+// @Security BearerAuth
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param Upload-Length header int true "Size of the photo in bytes"
+// @Param Upload-Metadata header string true "Comma-separated key and base64 value pairs"
+// @Success 201
+// @Header 201 {string} Location "URL of the upload"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /uploads/tus [post]
+func (h *TusHandler) CreateUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		respondError(w, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		respondError(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if length > service.MaxPhotoSize {
+		respondError(w, http.StatusRequestEntityTooLarge, "upload is too large")
+		return
+	}
+
+	metadata, ok := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if !ok {
+		respondError(w, http.StatusBadRequest, "invalid Upload-Metadata")
+		return
+	}
+	params := service.CreateResumableUploadParams{
+		SenderID: senderID,
+		Length:   length,
+		MimeType: metadata["filetype"],
+	}
+	if caption := metadata["caption"]; caption != "" {
+		params.Caption = &caption
+	}
+	if e := metadata["expires_at"]; e != "" {
+		expiresAt, err := time.Parse(time.RFC3339, e)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid expires_at")
+			return
+		}
+		params.ExpiresAt = &expiresAt
+	}
+	recipientIDs, ok := parseRecipientIDs(w, []string{metadata["recipient_ids"]})
+	if !ok {
+		return
+	}
+	params.RecipientIDs = recipientIDs
+
+	upload, err := h.uploads.Create(r.Context(), params)
+	if err != nil {
+		respondServiceError(w, err, "failed to create upload")
+		return
+	}
+
+	w.Header().Set("Location", h.basePath+upload.ID.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// GetOffset godoc
+// @Summary Get the offset of a resumable upload
+// @Description Report how many bytes of the upload were received, to resume from there
+// @Tags uploads
+// @Security BearerAuth
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Success 200
+// @Header 200 {integer} Upload-Offset "Bytes received"
+// @Header 200 {integer} Upload-Length "Size of the photo in bytes"
+// @Header 200 {string} X-Photo-ID "ID of the created photo, once complete"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Router /uploads/tus/{id} [head]
+func (h *TusHandler) GetOffset(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	uploadID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid upload ID")
+		return
+	}
+
+	upload, err := h.uploads.Get(r.Context(), uploadID, senderID)
+	if err != nil {
+		respondServiceError(w, err, "failed to get upload")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setUploadOffset(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+// AppendChunk godoc
+// @Summary Upload a chunk of a resumable upload
+// @Description Append the request body at Upload-Offset, which must equal the bytes received so far.
+// @Description The photo is created when the last byte arrives; its ID is returned in X-Photo-ID.
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Security BearerAuth
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param Upload-Offset header int true "Offset of the chunk"
+// @Success 204
+// @Header 204 {integer} Upload-Offset "Bytes received"
+// @Header 204 {string} X-Photo-ID "ID of the created photo, once complete"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /uploads/tus/{id} [patch]
+func (h *TusHandler) AppendChunk(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	uploadID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid upload ID")
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		respondError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		respondError(w, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+
+	upload, err := h.uploads.Append(r.Context(), uploadID, senderID, offset, r.Body)
+	if err != nil {
+		respondServiceError(w, err, "failed to append to upload")
+		return
+	}
+
+	setUploadOffset(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TerminateUpload godoc
+// @Summary Cancel a resumable upload
+// @Description Delete the upload and the bytes received so far. A photo already created is kept.
+// @Tags uploads
+// @Security BearerAuth
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 412 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /uploads/tus/{id} [delete]
+func (h *TusHandler) TerminateUpload(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	senderID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	uploadID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid upload ID")
+		return
+	}
+
+	if err := h.uploads.Terminate(r.Context(), uploadID, senderID); err != nil {
+		respondServiceError(w, err, "failed to terminate upload")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkTusVersion sets the Tus-Resumable response header and responds 412 if the
+// client speaks another protocol version
+func checkTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		respondError(w, http.StatusPreconditionFailed, "unsupported tus version")
+		return false
+	}
+	return true
+}
+
+// setUploadOffset sets the Upload-Offset header, and X-Photo-ID once the photo is created
+func setUploadOffset(w http.ResponseWriter, upload *service.ResumableUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.PhotoID != nil {
+		w.Header().Set("X-Photo-ID", upload.PhotoID.String())
+	}
+}
+
+// parseTusMetadata decodes an Upload-Metadata header: comma-separated pairs of a
+// key and a base64-encoded value, which may be omitted
+func parseTusMetadata(header string) (map[string]string, bool) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, false
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, false
+		}
+	}
+	return metadata, true
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/yourusername/yourproject/db"      // Update with your actual path
+	"github.com/yourusername/yourproject/service" // Update with your actual path
+)
+
+// fakeUploadStore keeps resumable uploads in memory like the resumable_uploads table
+type fakeUploadStore struct {
+	mu      sync.Mutex
+	uploads map[uuid.UUID]db.ResumableUpload
+}
+
+func newFakeUploadStore() *fakeUploadStore {
+	return &fakeUploadStore{uploads: make(map[uuid.UUID]db.ResumableUpload)}
+}
+
+func (f *fakeUploadStore) CreateResumableUpload(_ context.Context, arg db.CreateResumableUploadParams) (db.ResumableUpload, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u := db.ResumableUpload{
+		ID:             arg.ID,
+		SenderID:       arg.SenderID,
+		UploadLength:   arg.UploadLength,
+		MimeType:       arg.MimeType,
+		Caption:        arg.Caption,
+		PhotoExpiresAt: arg.PhotoExpiresAt,
+		RecipientIds:   arg.RecipientIds,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	f.uploads[u.ID] = u
+	return u, nil
+}
+
+func (f *fakeUploadStore) GetResumableUpload(_ context.Context, arg db.GetResumableUploadParams) (db.ResumableUpload, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.uploads[arg.ID]
+	if !ok || u.SenderID != arg.SenderID {
+		return db.ResumableUpload{}, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeUploadStore) AdvanceResumableUpload(_ context.Context, arg db.AdvanceResumableUploadParams) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.uploads[arg.ID]
+	if !ok || u.UploadOffset != arg.OldOffset || u.CompletedAt != nil {
+		return 0, nil
+	}
+	u.UploadOffset = arg.NewOffset
+	f.uploads[arg.ID] = u
+	return 1, nil
+}
+
+func (f *fakeUploadStore) DeleteResumableUpload(_ context.Context, arg db.DeleteResumableUploadParams) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	u, ok := f.uploads[arg.ID]
+	if !ok || u.SenderID != arg.SenderID {
+		return 0, nil
+	}
+	delete(f.uploads, arg.ID)
+	return 1, nil
+}
+
+func (f *fakeUploadStore) DeleteStaleResumableUploads(context.Context, db.DeleteStaleResumableUploadsParams) ([]db.DeleteStaleResumableUploadsRow, error) {
+	return nil, nil
+}
+
+func (f *fakeUploadStore) ListFriends(context.Context, uuid.UUID) ([]db.ListFriendsRow, error) {
+	return nil, nil
+}
+
+func (f *fakeUploadStore) CountFriendsAmong(context.Context, db.CountFriendsAmongParams) (int64, error) {
+	return 0, nil
+}
+
+// newTusRouter routes tus requests like main.go, to a new service instance keeping
+// its files in dir. Routers sharing a store and dir act as one server restarted.
+func newTusRouter(t *testing.T, store service.ResumableUploadStore, dir string) http.Handler {
+	t.Helper()
+	// Without a photo service, uploads must not be completed
+	uploads, err := service.NewResumableUploadService(nil, store, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewTusHandler(uploads, "/uploads/tus/")
+	r := mux.NewRouter()
+	r.HandleFunc("/uploads/tus", h.CreateUpload).Methods("POST")
+	r.HandleFunc("/uploads/tus/{id}", h.GetOffset).Methods("HEAD")
+	r.HandleFunc("/uploads/tus/{id}", h.AppendChunk).Methods("PATCH")
+	r.HandleFunc("/uploads/tus/{id}", h.TerminateUpload).Methods("DELETE")
+	return r
+}
+
+// tusRequest sends a tus request as userID
+func tusRequest(h http.Handler, userID uuid.UUID, method, target string, headers map[string]string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req = req.WithContext(WithUserID(req.Context(), userID))
+	req.Header.Set("Tus-Resumable", TusVersion)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// createTusUpload starts an upload of length bytes and returns its location
+func createTusUpload(t *testing.T, h http.Handler, userID uuid.UUID, length int) string {
+	t.Helper()
+	rec := tusRequest(h, userID, http.MethodPost, "/uploads/tus", map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "filetype aW1hZ2UvanBlZw==", // image/jpeg
+	}, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", rec.Code, rec.Body)
+	}
+	return rec.Header().Get("Location")
+}
+
+func patchTusUpload(h http.Handler, userID uuid.UUID, location string, offset int, chunk []byte) *httptest.ResponseRecorder {
+	return tusRequest(h, userID, http.MethodPatch, location, map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	}, chunk)
+}
+
+// tusOffset returns the Upload-Offset reported by a HEAD request
+func tusOffset(t *testing.T, h http.Handler, userID uuid.UUID, location string) string {
+	t.Helper()
+	rec := tusRequest(h, userID, http.MethodHead, location, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("head: got %d %s", rec.Code, rec.Body)
+	}
+	return rec.Header().Get("Upload-Offset")
+}
+
+// uploadFile returns the bytes of an upload received so far
+func uploadFile(t *testing.T, dir, location string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, location[strings.LastIndex(location, "/")+1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var tusPhoto = []byte("0123456789")
+
+func TestTusResumeAfterRestart(t *testing.T) {
+	store, dir, userID := newFakeUploadStore(), t.TempDir(), uuid.New()
+	h := newTusRouter(t, store, dir)
+	location := createTusUpload(t, h, userID, len(tusPhoto))
+
+	if rec := patchTusUpload(h, userID, location, 0, tusPhoto[:4]); rec.Code != http.StatusNoContent {
+		t.Fatalf("patch: got %d %s", rec.Code, rec.Body)
+	}
+
+	restarted := newTusRouter(t, store, dir)
+	rec := tusRequest(restarted, userID, http.MethodHead, location, nil, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("head: got %d %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Upload-Offset"); got != "4" {
+		t.Fatalf("offset %s after restart, want 4", got)
+	}
+	if got := rec.Header().Get("Upload-Length"); got != strconv.Itoa(len(tusPhoto)) {
+		t.Fatalf("length %s after restart, want %d", got, len(tusPhoto))
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Fatalf("Cache-Control %q, want no-store", got)
+	}
+
+	rec = patchTusUpload(restarted, userID, location, 4, tusPhoto[4:9])
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Upload-Offset") != "9" {
+		t.Fatalf("patch after restart: got %d offset %s", rec.Code, rec.Header().Get("Upload-Offset"))
+	}
+	if got := uploadFile(t, dir, location); !bytes.Equal(got, tusPhoto[:9]) {
+		t.Fatalf("received %q, want %q", got, tusPhoto[:9])
+	}
+
+	// Uploads belong to their sender
+	rec = tusRequest(restarted, uuid.New(), http.MethodHead, location, nil, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("head by another user: got %d, want 404", rec.Code)
+	}
+}
+
+func TestTusPatchChecksOffset(t *testing.T) {
+	store, dir, userID := newFakeUploadStore(), t.TempDir(), uuid.New()
+	h := newTusRouter(t, store, dir)
+	location := createTusUpload(t, h, userID, len(tusPhoto))
+	if rec := patchTusUpload(h, userID, location, 0, tusPhoto[:4]); rec.Code != http.StatusNoContent {
+		t.Fatalf("patch: got %d %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		want   int
+	}{
+		{"behind", 2, http.StatusConflict},
+		{"repeated", 0, http.StatusConflict},
+		{"ahead", 6, http.StatusConflict},
+		{"negative", -1, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := patchTusUpload(h, userID, location, tt.offset, []byte("xx"))
+			if rec.Code != tt.want {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+			if got := tusOffset(t, h, userID, location); got != "4" {
+				t.Fatalf("offset %s, want 4", got)
+			}
+			if got := uploadFile(t, dir, location); !bytes.Equal(got, tusPhoto[:4]) {
+				t.Fatalf("received %q, want %q", got, tusPhoto[:4])
+			}
+		})
+	}
+}
+
+func TestTusPatchRejectsOverLengthChunk(t *testing.T) {
+	store, dir, userID := newFakeUploadStore(), t.TempDir(), uuid.New()
+	h := newTusRouter(t, store, dir)
+	location := createTusUpload(t, h, userID, len(tusPhoto))
+	if rec := patchTusUpload(h, userID, location, 0, tusPhoto[:4]); rec.Code != http.StatusNoContent {
+		t.Fatalf("patch: got %d %s", rec.Code, rec.Body)
+	}
+
+	rec := patchTusUpload(h, userID, location, 4, append(bytes.Clone(tusPhoto[4:]), "extra"...))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got %d %s, want 400", rec.Code, rec.Body)
+	}
+	if got := tusOffset(t, h, userID, location); got != "4" {
+		t.Fatalf("offset %s after an over-length chunk, want 4", got)
+	}
+
+	// The rejected bytes are dropped when the upload resumes
+	if rec := patchTusUpload(h, userID, location, 4, tusPhoto[4:8]); rec.Code != http.StatusNoContent {
+		t.Fatalf("patch: got %d %s", rec.Code, rec.Body)
+	}
+	if got := uploadFile(t, dir, location); !bytes.Equal(got, tusPhoto[:8]) {
+		t.Fatalf("received %q, want %q", got, tusPhoto[:8])
+	}
+}
+
+func TestTusTerminate(t *testing.T) {
+	store, dir, userID := newFakeUploadStore(), t.TempDir(), uuid.New()
+	h := newTusRouter(t, store, dir)
+	location := createTusUpload(t, h, userID, len(tusPhoto))
+	if rec := patchTusUpload(h, userID, location, 0, tusPhoto[:4]); rec.Code != http.StatusNoContent {
+		t.Fatalf("patch: got %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := tusRequest(h, uuid.New(), http.MethodDelete, location, nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("delete by another user: got %d, want 404", rec.Code)
+	}
+	if rec := tusRequest(h, userID, http.MethodDelete, location, nil, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: got %d %s", rec.Code, rec.Body)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Fatalf("%d upload files left after termination", len(entries))
+	}
+	for _, method := range []string{http.MethodHead, http.MethodDelete} {
+		if rec := tusRequest(h, userID, method, location, nil, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s after termination: got %d, want 404", method, rec.Code)
+		}
+	}
+	if rec := patchTusUpload(h, userID, location, 4, tusPhoto[4:]); rec.Code != http.StatusNotFound {
+		t.Fatalf("patch after termination: got %d, want 404", rec.Code)
+	}
+}